@@ -5,12 +5,10 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"golang.org/x/net/proxy"
 	"gopkg.in/yaml.v3"
 	"log"
 	"net"
 	"net/http"
-	"net/url"
 	"os"
 	"sync"
 	"time"
@@ -47,64 +45,218 @@ func getData(key string) (interface{}, error) {
 	return val, nil
 }
 
-/**
- * GetProxy 拿到socks代理或者http代理
- * 同时封装超时时间
- */
-func GetProxy() (*http.Transport, error) {
-	var transport *http.Transport
-	raw, err := getData("TimeOut")
-	if err != nil {
-		return nil, err
-	}
-	duration, ok := raw.(int)
-	if !ok {
-		duration = 5 //赋默认值
+// Decode 把某个配置项解码到结构体中,用于嵌套的配置块,配置项不存在时保持 out 不变
+func Decode(key string, out interface{}) error {
+	raw, err := getData(key)
+	if err != nil || raw == nil {
+		return nil
 	}
-	//log.Println("成功加载超时时间:", duration, "秒")
-	timeout := time.Duration(duration) * time.Second
-	raw, err = getData("Proxy")
+	data, err := yaml.Marshal(raw)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("配置项 <%s> 序列化失败: %v", key, err)
 	}
-	rawP := raw.(string)
-	switch rawP[0] {
-	case 'h', 'H':
-		//http or https
-		parseUrl, err := url.Parse(rawP)
-		if err != nil {
-			return nil, fmt.Errorf("invalid HTTP proxy address: %v", err)
-		}
-		transport = &http.Transport{
-			Proxy:                 http.ProxyURL(parseUrl),
-			DisableKeepAlives:     true,
-			TLSHandshakeTimeout:   timeout,
-			ResponseHeaderTimeout: timeout,
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true, // 👈 跳过证书验证
-			},
+	if err := yaml.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("配置项 <%s> 格式错误: %v", key, err)
+	}
+	return nil
+}
+
+// getInt 读取正整数配置项,不存在或不合法时返回默认值
+func getInt(key string, def int) int {
+	if raw, err := getData(key); err == nil {
+		if v, ok := raw.(int); ok && v > 0 {
+			return v
 		}
-	case 's', 'S':
-		dialer, err := proxy.SOCKS5("tcp", rawP, nil, proxy.Direct)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create SOCKS5 dialer: %v", err)
+	}
+	return def
+}
+
+// GetTimeOut 超时时间,单位秒,默认5秒
+func GetTimeOut() time.Duration {
+	return time.Duration(getInt("TimeOut", 5)) * time.Second
+}
 
+// GetDialTimeOut 建立连接(含连接代理)的超时时间,单位秒,默认同 TimeOut
+func GetDialTimeOut() time.Duration {
+	return time.Duration(getInt("DialTimeOut", getInt("TimeOut", 5))) * time.Second
+}
+
+// GetRequestTimeOut 单个请求从建连到读完响应体的总超时,单位秒,默认为 TimeOut 的3倍
+func GetRequestTimeOut() time.Duration {
+	return time.Duration(getInt("RequestTimeOut", 3*getInt("TimeOut", 5))) * time.Second
+}
+
+// GetBodyTimeOut 收到响应头之后读取响应体的超时时间,单位秒,默认同 TimeOut
+// 超时后已读到的部分仍然参与匹配,并标记为截断
+func GetBodyTimeOut() time.Duration {
+	return time.Duration(getInt("BodyTimeOut", getInt("TimeOut", 5))) * time.Second
+}
+
+// GetMaxBodySize 响应体最多读取的字节数,默认2MB,超出部分丢弃并标记为截断
+func GetMaxBodySize() int {
+	return getInt("MaxBodySize", 2<<20)
+}
+
+// GetRetries 短暂性失败(超时、连接重置、429/503)的重试次数,默认2次,写0关闭重试
+func GetRetries() int {
+	if raw, err := getData("Retries"); err == nil {
+		if v, ok := raw.(int); ok && v >= 0 {
+			return v
 		}
-		// 转为 net.DialContext
-		dialContext := func(network, addr string) (net.Conn, error) {
-			return dialer.Dial(network, addr)
+	}
+	return 2
+}
+
+// GetRetryBackoff 第一次重试前的等待时间,单位毫秒,默认500,之后每次翻倍
+func GetRetryBackoff() time.Duration {
+	return time.Duration(getInt("RetryBackoff", 500)) * time.Millisecond
+}
+
+// RateLimit 限速设置,Global/PerHost 为每秒请求数,0 表示不限制
+type RateLimit struct {
+	Global  float64 `yaml:"Global"`
+	PerHost float64 `yaml:"PerHost"`
+	Burst   int     `yaml:"Burst"`  // 允许突发的请求数,默认1
+	Jitter  int     `yaml:"Jitter"` // 每个请求前额外随机等待 0~Jitter 毫秒
+}
+
+// GetRateLimit 读取 RateLimit 配置块,不存在时不限速
+func GetRateLimit() RateLimit {
+	var limit RateLimit
+	if err := Decode("RateLimit", &limit); err != nil {
+		log.Println(err)
+		return RateLimit{}
+	}
+	return limit
+}
+
+// Soft404Check 精准模式下是否用随机路径的基线过滤通配响应,默认开启
+func Soft404Check() bool {
+	raw, err := getData("Soft404Check")
+	if err != nil {
+		return true
+	}
+	check, ok := raw.(bool)
+	if !ok {
+		log.Println("Soft404Check配置项不是布尔类型,默认开启")
+		return true
+	}
+	return check
+}
+
+// GetStateFile 断点续扫的状态文件路径,默认 printraptor.state
+func GetStateFile() string {
+	if raw, err := getData("StateFile"); err == nil {
+		if path, ok := raw.(string); ok && path != "" {
+			return path
 		}
-		transport = &http.Transport{
-			Dial:                  dialContext,
-			DisableKeepAlives:     true,
-			TLSHandshakeTimeout:   timeout,
-			ResponseHeaderTimeout: timeout,
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true, // 👈 跳过证书验证
-			},
+	}
+	return "printraptor.state"
+}
+
+// GetCacheDir 响应缓存目录,未配置时返回空,不缓存
+func GetCacheDir() string {
+	if raw, err := getData("CacheDir"); err == nil {
+		if dir, ok := raw.(string); ok {
+			return dir
 		}
-	default:
-		return nil, fmt.Errorf("unsupported proxy type: %s", rawP)
+	}
+	return ""
+}
+
+// Waf WAF/CDN检测设置
+type Waf struct {
+	Detect  bool   `yaml:"Detect"`  // 是否检测,默认开启
+	OnBlock string `yaml:"OnBlock"` // 遇到拦截页后: continue 照常探测 / backoff 退避 / stop 停止请求特殊路径
+	Backoff int    `yaml:"Backoff"` // backoff 模式下首次等待的毫秒数,默认3000
+}
+
+// GetWaf 读取 Waf 配置块
+func GetWaf() Waf {
+	waf := Waf{Detect: true, OnBlock: "continue", Backoff: 3000}
+	if err := Decode("Waf", &waf); err != nil {
+		log.Println(err)
+	}
+	return waf
+}
+
+// Crawl 同源爬取设置
+type Crawl struct {
+	Depth    int `yaml:"Depth"`    // 从根路径开始爬取的深度,0为不爬取
+	MaxPages int `yaml:"MaxPages"` // 每个目标最多爬取的页面数,默认20
+}
+
+// GetCrawl 读取同源爬取设置,默认不爬取
+func GetCrawl() Crawl {
+	crawl := Crawl{MaxPages: 20}
+	if err := Decode("Crawl", &crawl); err != nil {
+		log.Println(err)
+	}
+	return crawl
+}
+
+// JsAnalysis 脚本和 source map 分析设置
+type JsAnalysis struct {
+	Enable     bool `yaml:"Enable"`     // 是否请求根路径引用的脚本,默认开启,只在有规则用到 js 字段时生效
+	MaxScripts int  `yaml:"MaxScripts"` // 每个目标最多请求的脚本数,默认10
+}
+
+// GetJsAnalysis 读取脚本分析设置
+func GetJsAnalysis() JsAnalysis {
+	js := JsAnalysis{Enable: true, MaxScripts: 10}
+	if err := Decode("JsAnalysis", &js); err != nil {
+		log.Println(err)
+	}
+	return js
+}
+
+// Vhost 虚拟主机候选名的来源
+type Vhost struct {
+	FromCert   bool `yaml:"FromCert"`   // 从 https 目标的证书(SAN、CN)中获取
+	ReverseDNS bool `yaml:"ReverseDNS"` // 对 IP 目标做反向解析
+	Max        int  `yaml:"Max"`        // 每个目标最多尝试的虚拟主机数,默认10
+}
+
+// GetVhost 读取虚拟主机设置,默认只使用目标文件中写的主机名
+func GetVhost() Vhost {
+	vhost := Vhost{Max: 10}
+	if err := Decode("Vhost", &vhost); err != nil {
+		log.Println(err)
+	}
+	return vhost
+}
+
+// GetMaxConnsPerHost 每个主机(或代理)同时打开的最大连接数,默认10
+func GetMaxConnsPerHost() int {
+	return getInt("MaxConnsPerHost", 10)
+}
+
+/**
+ * GetProxy 构造请求使用的 Transport
+ * 代理由代理池按请求分配(见 proxy.go),同时封装超时时间;
+ * 连接会按主机复用,应当只构造一次供所有请求共享
+ */
+func GetProxy() (*http.Transport, error) {
+	pool, err := GetProxyPool()
+	if err != nil {
+		return nil, err
+	}
+	timeout := GetTimeOut()
+	//log.Println("成功加载超时时间:", duration, "秒")
+	transport := &http.Transport{
+		// http/https/socks5 代理都由 net/http 处理,账号密码取自代理地址
+		Proxy:                 pool.ProxyFunc(),
+		DialContext:           (&net.Dialer{Timeout: GetDialTimeOut(), KeepAlive: 30 * time.Second}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   GetMaxConnsPerHost(),
+		MaxConnsPerHost:       GetMaxConnsPerHost(),
+		IdleConnTimeout:       90 * time.Second,
+		ForceAttemptHTTP2:     true, // 自定义了 TLSClientConfig 后需要显式开启 HTTP/2
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true, // 👈 跳过证书验证
+		},
 	}
 	return transport, nil
 }
@@ -157,19 +309,76 @@ func GetTargetFilePath() (string, error) {
 	return filePath, nil
 
 }
-func GetFingerFilePath() (string, error) {
+
+// GetFingerFilePaths 指纹文件路径,既可以写单个字符串,也可以写列表
+// 每一项可以是文件、目录(递归加载其中的yaml)或通配符
+// 未配置时返回空列表,只使用内置指纹库
+func GetFingerFilePaths() ([]string, error) {
 	raw, err := getData("FingerFilePath")
+	if err != nil || raw == nil {
+		return nil, nil
+	}
+	var paths []string
+	switch v := raw.(type) {
+	case string:
+		if v != "" {
+			paths = append(paths, v)
+		}
+	case []interface{}:
+		for _, item := range v {
+			path, ok := item.(string)
+			if !ok {
+				return nil, errors.New("FingerFilePath列表中的每一项必须是字符串类型")
+			}
+			if path != "" {
+				paths = append(paths, path)
+			}
+		}
+	default:
+		return nil, errors.New("FingerFilePath配置项必须是字符串或字符串列表")
+	}
+	return paths, nil
+}
+
+// UseBuiltinRules 是否加载内置指纹库,默认加载
+func UseBuiltinRules() bool {
+	raw, err := getData("UseBuiltinRules")
 	if err != nil {
-		return "", err
+		return true
 	}
-	filePath, ok := raw.(string)
+	use, ok := raw.(bool)
 	if !ok {
-		return "", errors.New("FingerFilePath配置项必须是字符串类型")
+		log.Println("UseBuiltinRules配置项不是布尔类型,默认加载内置指纹库")
+		return true
 	}
-	if filePath == "" {
-		return "", errors.New("FingerFilePath配置项不能为空")
+	return use
+}
+
+// GetDisabledRules 按名字禁用的规则,对内置指纹库和用户指纹都生效
+func GetDisabledRules() []string {
+	raw, err := getData("DisableRules")
+	if err != nil {
+		return nil
 	}
-	return filePath, nil
+	return toStringList(raw)
+}
+
+// toStringList 把yaml中的字符串或字符串列表统一转成[]string,非字符串项忽略
+func toStringList(raw interface{}) []string {
+	var list []string
+	switch v := raw.(type) {
+	case string:
+		if v != "" {
+			list = append(list, v)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				list = append(list, s)
+			}
+		}
+	}
+	return list
 }
 func IsFastMode() bool {
 	raw, err := getData("FastMode")
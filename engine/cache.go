@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	"PrintRaptor/models"
+	"log"
+)
+
+/*
+响应缓存
+在线扫描时把拿到的响应按请求写入缓存;离线模式不发任何请求,所有响应都从缓存中取,
+缓存里没有的请求按失败处理。随机路径的基线固定存在 baselineKey 下,
+同一地址的不同虚拟主机分开缓存
+*/
+
+const baselineKey = "BASELINE"
+
+// cacheKey 响应在缓存中的键,与 requestKey 相同,基线请求的路径每次随机,单独使用固定的键
+func (sc *scan) cacheKey(rule *fingerprints.CompiledRule, key string) string {
+	if rule.Name == baselineName && rule.Path == sc.baselinePath {
+		return baselineKey
+	}
+	return key
+}
+
+// loadCache 扫描开始时读取目标的缓存,只有离线模式需要
+func (sc *scan) loadCache() {
+	if sc.Cache == nil || !sc.offline {
+		return
+	}
+	cached, err := sc.Cache.Load(models.TargetKey(sc.u, sc.vhost))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	sc.cached = cached
+}
+
+// remember 记录一个在线拿到的响应,扫描结束时写入缓存,被中断时由 ResumeVhost 返回给调用方保存
+func (sc *scan) remember(key string, data *fingerprints.ResponseData) {
+	if sc.offline || data == nil {
+		return
+	}
+	if sc.fresh == nil {
+		sc.fresh = make(map[string]*fingerprints.ResponseData)
+	}
+	sc.fresh[key] = data
+}
+
+// saveCache 把本次拿到的响应合并进目标已有的缓存
+func (sc *scan) saveCache() {
+	if sc.Cache == nil || len(sc.fresh) == 0 {
+		return
+	}
+	merged, err := sc.Cache.Load(models.TargetKey(sc.u, sc.vhost))
+	if err != nil {
+		log.Println(err)
+		merged = make(map[string]*fingerprints.ResponseData)
+	}
+	for key, data := range sc.fresh {
+		merged[key] = data
+	}
+	if err := sc.Cache.Save(models.TargetKey(sc.u, sc.vhost), merged); err != nil {
+		log.Println(err)
+	}
+}
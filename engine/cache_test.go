@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	"PrintRaptor/models"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// TestOfflineRematch 在线扫描写入缓存,服务关闭后用新规则离线重新匹配
+func TestOfflineRematch(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Server", "Apache-Coyote/1.1")
+			w.Write([]byte("<title>index</title>"))
+		case "/manager/html":
+			w.WriteHeader(nethttp.StatusUnauthorized)
+			w.Write([]byte("<title>401 Unauthorized</title> Tomcat Manager Application"))
+		default:
+			nethttp.NotFound(w, r)
+		}
+	}))
+	u, _ := url.Parse(server.URL)
+	cache, err := models.OpenResponseCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	load := func(yaml string) []fingerprints.CompiledRule {
+		rules, err := fingerprints.LoadRulesFromBytes("test", []byte(yaml))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rules
+	}
+
+	online := NewScanner(load(`
+- name: placeholder
+  path: /manager/html
+  expression: body="nothing"
+`), false)
+	online.Cache = cache
+	if result := online.Scan(u); len(result.Products) != 0 {
+		t.Fatalf("在线扫描不应命中: %+v", result.Products)
+	}
+	server.Close()
+
+	offline := NewScanner(load(`
+- name: tomcat
+  expression: header="Coyote"
+- name: tomcat-manager
+  path: /manager/html
+  expression: body="Tomcat Manager" && status="401"
+- name: never-fetched
+  path: /other
+  expression: body="index"
+`), false)
+	offline.Cache = cache
+	offline.Offline = true
+	result := offline.Scan(u)
+	if len(result.Products) != 2 || !result.Has("tomcat") || !result.Has("tomcat-manager") {
+		t.Fatalf("期望离线识别出 tomcat 和 tomcat-manager, 实际 %+v", result.Products)
+	}
+	if len(result.Errors) != 0 || result.Unreachable {
+		t.Fatalf("离线模式不应产生请求错误: %+v", result.Errors)
+	}
+	targets, _ := cache.Targets()
+	if len(targets) != 1 || targets[0] != u.String() {
+		t.Fatalf("缓存目标错误: %v", targets)
+	}
+}
+
+// TestScanImported 导入的流量按目标分组,与在线扫描一样匹配根路径和特殊路径的规则
+func TestScanImported(t *testing.T) {
+	rules, err := fingerprints.LoadRulesFromBytes("test", []byte(`
+- name: nginx
+  expression: header="nginx"
+- name: tomcat-manager
+  path: /manager/html
+  expression: body="Tomcat Manager"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := func(url, header, body string, status int) fingerprints.ImportedResponse {
+		return fingerprints.ImportedResponse{Method: "GET", Data: &fingerprints.ResponseData{URL: url, Headers: header, Body: body, Status: status}}
+	}
+	results := NewScanner(rules, false).ScanImported([]fingerprints.ImportedResponse{
+		data("http://a.local/", "Server: nginx\r\n", "<title>a</title>", 200),
+		data("http://b.local/manager/html", "", "Tomcat Manager", 401),
+	})
+	if len(results) != 2 {
+		t.Fatalf("期望 2 个目标, 实际 %d", len(results))
+	}
+	if !results[0].Has("nginx") || results[1].Has("nginx") || !results[1].Has("tomcat-manager") {
+		t.Fatalf("导入流量匹配错误: %+v / %+v", results[0].Products, results[1].Products)
+	}
+	if results[1].Unreachable {
+		t.Fatal("导入的流量没有根路径时不应标记为不可达")
+	}
+}
+
+// TestScanImportedPostBodies 同一路径的多个POST按请求体区分,没写请求体的规则用第一条
+func TestScanImportedPostBodies(t *testing.T) {
+	rules, err := fingerprints.LoadRulesFromBytes("test", []byte(`
+- name: login-a
+  path: /login
+  method: POST
+  body: u=a
+  expression: body="welcome a"
+- name: login-b
+  path: /login
+  method: POST
+  body: u=b
+  expression: body="welcome b"
+- name: login-any
+  path: /login
+  isPost: true
+  expression: body="welcome a"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func(body, resp string) fingerprints.ImportedResponse {
+		return fingerprints.ImportedResponse{Method: "POST", Body: []byte(body), Data: &fingerprints.ResponseData{URL: "http://a.local/login", Body: resp, Status: 200}}
+	}
+	results := NewScanner(rules, false).ScanImported([]fingerprints.ImportedResponse{
+		post("u=a", "welcome a"),
+		post("u=b", "welcome b"),
+	})
+	if len(results) != 1 {
+		t.Fatalf("期望 1 个目标, 实际 %d", len(results))
+	}
+	for _, name := range []string{"login-a", "login-b", "login-any"} {
+		if !results[0].Has(name) {
+			t.Errorf("缺少 %s, 实际 %+v", name, results[0].Products)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	"log"
+)
+
+// runChain 按顺序执行多步请求,前面步骤提取的变量替换进后续步骤的路径
+// 所有写了 expression 的步骤都命中才算命中,返回最后一步的地址
+func (sc *scan) runChain(rule *fingerprints.CompiledRule) (string, bool) {
+	vars := make(map[string]string)
+	var last *fingerprints.ResponseData
+	for i := range rule.Steps {
+		step := &rule.Steps[i]
+		stepRule := &fingerprints.CompiledRule{RuleConfig: fingerprints.RuleConfig{
+			Name:           rule.Name,
+			Path:           fingerprints.ExpandVars(step.Path, vars),
+			IsPost:         step.IsPost,
+			RequestOptions: step.RequestOptions.Expand(vars),
+		}}
+		data := sc.fetch(stepRule)
+		if data == nil {
+			return "", false
+		}
+		if step.AST != nil && !step.AST.Eval(data) {
+			return "", false
+		}
+		if missing := step.ExtractVars(data, vars); len(missing) > 0 {
+			log.Printf("规则 %s 第 %d 步未提取到变量 %v", rule.Name, i+1, missing)
+			return "", false
+		}
+		last = data
+	}
+	return last.URL, true
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+/*
+同源爬取
+根路径里往往看不出后台用了什么,引用的JS、CSS和二级页面里才有特征。
+精准模式下请求完根路径后,按 href/src 广度优先请求同源链接,
+深度和页面数都有上限;爬到的每个页面都拿通用规则(根路径规则)匹配,命中记录页面自己的地址
+*/
+
+// defaultCrawlPages 每个目标默认最多爬取的页面数
+const defaultCrawlPages = 20
+
+const crawlName = "crawl"
+
+var linkPattern = regexp.MustCompile(`(?i)(?:href|src)\s*=\s*["']?([^"'\s>]+)`)
+
+// skipExts 图片、字体、媒体、压缩包等不含特征的资源,不请求
+var skipExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".bmp": true, ".ico": true, ".svg": true, ".webp": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".eot": true, ".otf": true,
+	".mp3": true, ".mp4": true, ".avi": true, ".webm": true, ".flv": true,
+	".zip": true, ".rar": true, ".7z": true, ".gz": true, ".tar": true, ".exe": true, ".apk": true,
+	".pdf": true, ".doc": true, ".docx": true, ".xls": true, ".xlsx": true, ".ppt": true, ".pptx": true,
+}
+
+// leafExts 这些资源里的链接不再继续爬取
+var leafExts = map[string]bool{".js": true, ".css": true, ".json": true, ".xml": true, ".txt": true, ".map": true}
+
+// crawlRule 爬取页面使用的占位规则
+func crawlRule(path string) *fingerprints.CompiledRule {
+	return &fingerprints.CompiledRule{RuleConfig: fingerprints.RuleConfig{Name: crawlName, Path: path}}
+}
+
+// crawl 从根路径开始爬取同源页面,结果存入 sc.pages
+func (sc *scan) crawl(root *fingerprints.ResponseData) {
+	if sc.CrawlDepth <= 0 || sc.FastMode || root == nil {
+		return
+	}
+	limit := sc.CrawlPages
+	if limit <= 0 {
+		limit = defaultCrawlPages
+	}
+	seen := map[string]bool{"/": true}
+	level := []*fingerprints.ResponseData{root}
+	for depth := 1; depth <= sc.CrawlDepth && len(level) > 0; depth++ {
+		var next []*fingerprints.ResponseData
+		for _, page := range level {
+			for _, link := range sc.links(page) {
+				if seen[link] {
+					continue
+				}
+				seen[link] = true
+				if len(sc.pages) >= limit || sc.ctx.Err() != nil {
+					return
+				}
+				data := sc.fetch(crawlRule(link))
+				if data == nil {
+					continue
+				}
+				sc.pages = append(sc.pages, data)
+				if !leafExts[linkExt(link)] {
+					next = append(next, data)
+				}
+			}
+		}
+		level = next
+	}
+}
+
+// links 页面中引用的同源地址,返回路径+查询参数
+func (sc *scan) links(page *fingerprints.ResponseData) []string {
+	base := sc.baseURL(page)
+	var links []string
+	for _, m := range linkPattern.FindAllStringSubmatch(page.Body, -1) {
+		if link, ok := sc.sameOrigin(base, m[1]); ok && !skipExts[linkExt(link)] {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// baseURL 页面中相对地址的基准,取页面实际请求的地址
+func (sc *scan) baseURL(page *fingerprints.ResponseData) *url.URL {
+	if page.URL != "" {
+		if u, err := url.Parse(page.URL); err == nil {
+			return u
+		}
+	}
+	return sc.u
+}
+
+// sameOrigin 把页面中的引用解析为绝对地址,与目标同源时返回路径+查询参数
+func (sc *scan) sameOrigin(base *url.URL, ref string) (string, bool) {
+	u, err := base.Parse(strings.TrimSpace(ref))
+	if err != nil || u.Scheme != sc.u.Scheme || u.Host != sc.u.Host {
+		return "", false
+	}
+	link := u.EscapedPath()
+	if link == "" {
+		link = "/"
+	}
+	if u.RawQuery != "" {
+		link += "?" + u.RawQuery
+	}
+	return link, true
+}
+
+func linkExt(link string) string {
+	if i := strings.IndexByte(link, '?'); i >= 0 {
+		link = link[:i]
+	}
+	return strings.ToLower(path.Ext(link))
+}
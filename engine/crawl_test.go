@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestScanCrawl 通用规则在爬取到的同源资源上命中,记录资源自己的地址;外站链接、图片和超出深度的页面不请求
+func TestScanCrawl(t *testing.T) {
+	var mu sync.Mutex
+	requested := make(map[string]bool)
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		mu.Lock()
+		requested[r.URL.RequestURI()] = true
+		mu.Unlock()
+		switch r.URL.RequestURI() {
+		case "/":
+			w.Write([]byte(`<html><script src="/static/app.js?v=1"></script><a href='about.html'>about</a>
+<img src="/logo.png"><a href="http://other.example/x.js">x</a><a href="#top">top</a></html>`))
+		case "/static/app.js?v=1":
+			w.Write([]byte(`/*! Vue.js v2.6.14 */ var a = "/static/chunk.js";`))
+		case "/about.html":
+			w.Write([]byte(`<a href="/deep.html">deep</a>`))
+		default:
+			nethttp.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	rules, err := fingerprints.LoadRulesFromBytes("test", []byte(`
+- name: vue
+  expression: body="Vue.js v2"
+- name: deep
+  expression: body="deep page"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := NewScanner(rules, false)
+	scanner.CrawlDepth = 1
+	u, _ := url.Parse(server.URL)
+	result := scanner.Scan(u)
+
+	if !result.Has("vue") || result.Has("deep") {
+		t.Fatalf("爬取识别结果错误: %+v", result.Products)
+	}
+	if hit := result.Products[0].Hits[0].URL; !strings.HasSuffix(hit, "/static/app.js?v=1") {
+		t.Fatalf("命中应记录资源地址, 实际 %s", hit)
+	}
+	for _, path := range []string{"/logo.png", "/deep.html", "/static/chunk.js", "/x.js"} {
+		if requested[path] {
+			t.Fatalf("不应请求 %s", path)
+		}
+	}
+	if !requested["/about.html"] {
+		t.Fatal("未爬取同源页面 /about.html")
+	}
+
+	// 不开启爬取时只看根路径
+	scanner.CrawlDepth = 0
+	if result := scanner.Scan(u); result.Has("vue") {
+		t.Fatalf("未开启爬取时不应命中, 实际 %+v", result.Products)
+	}
+}
@@ -0,0 +1,349 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	"PrintRaptor/http"
+	"PrintRaptor/models"
+	"context"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+/*
+扫描引擎
+根路径只请求一次,通用规则全部复用这一份响应;
+特殊路径的规则按依赖关系分轮评估: requires 中的产品都识别出来了才会去发包,
+每轮结束后根据 implies 推断上级产品,直到没有新的规则可以评估
+*/
+
+// rootRule 根路径请求使用的占位规则
+var rootRule = &fingerprints.CompiledRule{RuleConfig: fingerprints.RuleConfig{Name: "root", Path: "/"}}
+
+// 遇到WAF拦截页之后的处理方式
+const (
+	OnBlockContinue = "continue" // 照常探测
+	OnBlockBackoff  = "backoff"  // 之后的每个请求前等待 BlockBackoff,每多遇到一次拦截页等待时间加倍
+	OnBlockStop     = "stop"     // 不再请求特殊路径
+)
+
+// 拦截页退避的上限: 最多翻倍 maxBlockBackoffShift 次,单次等待不超过 maxBlockBackoff
+const (
+	maxBlockBackoffShift = 6
+	maxBlockBackoff      = 2 * time.Minute
+)
+
+// blockBackoff 第 blocks 次遇到拦截页后每个请求前的等待时间
+func blockBackoff(base time.Duration, blocks int) time.Duration {
+	if base <= 0 || blocks <= 0 {
+		return 0
+	}
+	shift := blocks - 1
+	if shift > maxBlockBackoffShift {
+		shift = maxBlockBackoffShift
+	}
+	if d := base << shift; d > 0 && d < maxBlockBackoff {
+		return d
+	}
+	return maxBlockBackoff
+}
+
+// sleep 等待 d,ctx 取消时提前返回
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// Scanner 持有加载好的规则,对每个目标执行一次完整的识别
+type Scanner struct {
+	Rules    []fingerprints.CompiledRule
+	FastMode bool // 快速模式只请求根路径,所有规则都用根路径的响应匹配
+
+	Waf          []fingerprints.CompiledRule // WAF/CDN识别规则,为空时不做检测
+	OnBlock      string                      // 遇到拦截页后的处理方式,默认 continue
+	BlockBackoff time.Duration
+	Soft404      bool // 精准模式下用随机路径的基线过滤与不存在的路径无法区分的响应,默认开启
+	CrawlDepth   int  // 精准模式下从根路径开始同源爬取的深度,0为不爬取,见 crawl.go
+	CrawlPages   int  // 每个目标最多爬取的页面数,默认20
+	JSAnalysis   bool // 精准模式下请求根路径引用的脚本和 source map 填充 js 字段,只在有规则用到 js 时生效,默认开启
+	MaxScripts   int  // 每个目标最多请求的脚本数,默认10
+
+	VhostFromCert   bool // 从目标的 TLS 证书中获取候选虚拟主机名,见 vhost.go
+	VhostReverseDNS bool // 对 IP 目标做反向解析获取候选虚拟主机名
+	MaxVhosts       int  // 每个目标最多尝试的虚拟主机数,默认10
+
+	Cache   *models.ResponseCache // 不为空时在线扫描拿到的响应都写入缓存
+	Offline bool                  // 离线模式,不发请求,只对 Cache 中的响应重新匹配
+
+	implies map[string][]string // 规则名 -> 可推断的上级产品,汇总同名规则
+	needJS  bool                // 是否有规则用到了 js 字段
+}
+
+func NewScanner(rules []fingerprints.CompiledRule, fastMode bool) *Scanner {
+	s := &Scanner{Rules: rules, FastMode: fastMode, Soft404: true, JSAnalysis: true, implies: make(map[string][]string)}
+	for _, rule := range rules {
+		key := strings.ToLower(rule.Name)
+		s.implies[key] = append(s.implies[key], rule.Implies...)
+		if rule.AST != nil && fingerprints.UsesField(rule.AST, "js") {
+			s.needJS = true
+		}
+	}
+	return s
+}
+
+// isRootRule 根路径上不带额外参数的GET规则,可以直接复用根路径的响应
+func isRootRule(rule *fingerprints.CompiledRule) bool {
+	return len(rule.Steps) == 0 && requestKey(rule) == requestKey(rootRule)
+}
+
+// requestKey 方法、路径、请求头、请求体都相同的规则共用一次响应
+func requestKey(rule *fingerprints.CompiledRule) string {
+	if rule.Raw != "" {
+		return "RAW\n" + rule.Raw
+	}
+	path := rule.Path
+	if path == "" {
+		path = "/"
+	}
+	return rule.HTTPMethod(rule.IsPost) + " " + path + "\n" + rule.RequestOptions.Key()
+}
+
+// scan 一次扫描的状态
+type scan struct {
+	*Scanner
+	ctx       context.Context
+	u         *url.URL
+	vhost     string // 请求使用的虚拟主机名,为空时使用 u 的主机
+	result    *models.HostResult
+	responses map[string]*fingerprints.ResponseData // requestKey -> 响应,失败或为拦截页时为nil
+	blocks    int                                   // 遇到的拦截页数量
+
+	pages        []*fingerprints.ResponseData // 爬取到的同源页面,见 crawl.go
+	baseline     *fingerprints.ResponseData   // 随机路径的响应,见 soft404.go
+	baselinePath string
+
+	offline bool                                  // 不发请求,只用 cached 中的响应
+	cached  map[string]*fingerprints.ResponseData // 离线模式下目标的缓存或导入的流量,见 cache.go
+	fresh   map[string]*fingerprints.ResponseData // 本次在线拿到的响应,写入缓存,被中断时作为续扫的检查点
+	resumed map[string]*fingerprints.ResponseData // 上次被中断前拿到的响应,见 ResumeVhost
+}
+
+// Scan 识别单个目标,返回聚合后的结果
+func (s *Scanner) Scan(u *url.URL) *models.HostResult {
+	return s.ScanContext(context.Background(), u)
+}
+
+// ScanContext 同 Scan,ctx 取消后不再发出新的请求,返回已有的部分结果并标记为中断
+func (s *Scanner) ScanContext(ctx context.Context, u *url.URL) *models.HostResult {
+	return s.ScanVhost(ctx, u, "")
+}
+
+// ScanVhost 同 ScanContext,连接建立到 u,Host 头和 SNI 使用 vhost
+func (s *Scanner) ScanVhost(ctx context.Context, u *url.URL, vhost string) *models.HostResult {
+	result, _ := s.ResumeVhost(ctx, u, vhost, nil)
+	return result
+}
+
+// ResumeVhost 同 ScanVhost,resumed 中已有的请求(键同响应缓存)直接复用不再发送;
+// 另外返回本次拿到的所有响应,扫描被中断时保存下来,续扫时再传回 resumed
+func (s *Scanner) ResumeVhost(ctx context.Context, u *url.URL, vhost string, resumed map[string]*fingerprints.ResponseData) (*models.HostResult, map[string]*fingerprints.ResponseData) {
+	sc := s.newScan(ctx, u)
+	sc.vhost = vhost
+	sc.result.Vhost = vhost
+	sc.offline = s.Offline
+	sc.resumed = resumed
+	sc.loadCache()
+	defer sc.saveCache()
+	return sc.run(), sc.fresh
+}
+
+func (s *Scanner) newScan(ctx context.Context, u *url.URL) *scan {
+	return &scan{
+		Scanner:   s,
+		ctx:       ctx,
+		u:         u,
+		result:    models.NewHostResult(u.Host),
+		responses: make(map[string]*fingerprints.ResponseData),
+	}
+}
+
+// run 对目标执行一次完整的识别
+func (sc *scan) run() *models.HostResult {
+	s, ctx, u := sc.Scanner, sc.ctx, sc.u
+	// tcp/udp 目标只做banner探测,不请求根路径
+	service := fingerprints.IsServiceProtocol(u.Scheme)
+	var root *fingerprints.ResponseData
+	if !service {
+		root = sc.fetch(rootRule)
+		if ctx.Err() != nil {
+			sc.result.Interrupted = true
+			return sc.result
+		}
+		// 离线时缓存或导入的流量里可能没有根路径,特殊路径的规则照常匹配
+		if root == nil && !sc.offline {
+			// 根路径就被拦截时不算不可达
+			sc.result.Unreachable = sc.blocks == 0
+			return sc.result
+		}
+		if root != nil {
+			sc.result.SetInfo(root)
+		}
+		sc.analyzeJS(root)
+		sc.crawl(root)
+	}
+
+	done := make([]bool, len(s.Rules))
+	for ctx.Err() == nil {
+		progress := false
+		for i := range s.Rules {
+			rule := &s.Rules[i]
+			if done[i] || ctx.Err() != nil {
+				continue
+			}
+			if !sc.applicable(rule) {
+				done[i] = true
+				continue
+			}
+			if !sc.requiresMet(rule) {
+				continue
+			}
+			done[i] = true
+			progress = true
+			if len(rule.Steps) > 0 {
+				// 快速模式只请求根路径,多步规则的表达式针对的是各步自己的响应,拿根路径匹配会误报
+				if s.FastMode {
+					continue
+				}
+				if hitURL, ok := sc.runChain(rule); ok {
+					sc.result.AddHit(rule, hitURL)
+				}
+				continue
+			}
+			data := root
+			if service {
+				data = sc.probe(rule)
+			} else if !s.FastMode && !isRootRule(rule) {
+				data = sc.fetch(rule)
+			}
+			if data != nil {
+				sc.result.Add(&models.Banner{ResponseData: data, CompiledRule: rule})
+			}
+			// 通用规则在爬取到的页面上也匹配一遍
+			if !service && isRootRule(rule) {
+				for _, page := range sc.pages {
+					sc.result.Add(&models.Banner{ResponseData: page, CompiledRule: rule})
+				}
+			}
+		}
+		// 新推断出的产品可能满足其他规则的 requires,再来一轮
+		if sc.infer() {
+			progress = true
+		}
+		if !progress {
+			break
+		}
+	}
+	sc.result.Interrupted = ctx.Err() != nil
+	sc.result.Finish()
+	return sc.result
+}
+
+// fetch 发送规则对应的请求,同一请求只发一次
+func (sc *scan) fetch(rule *fingerprints.CompiledRule) *fingerprints.ResponseData {
+	key := requestKey(rule)
+	if data, ok := sc.responses[key]; ok {
+		return data
+	}
+	if sc.ctx.Err() != nil {
+		return nil
+	}
+	cacheKey := sc.cacheKey(rule, key)
+	resumed, isResumed := sc.resumed[cacheKey]
+	if sc.blocks > 0 {
+		switch sc.OnBlock {
+		case OnBlockStop:
+			return nil
+		case OnBlockBackoff:
+			if !sc.offline && !isResumed {
+				sleep(sc.ctx, blockBackoff(sc.BlockBackoff, sc.blocks))
+			}
+		}
+	}
+	var data *fingerprints.ResponseData
+	if sc.offline {
+		data = sc.cached[cacheKey]
+	} else if isResumed {
+		data = resumed
+		sc.remember(cacheKey, data)
+	} else {
+		target, err := http.NewTarget(sc.u, rule)
+		if err == nil {
+			target.Vhost = sc.vhost
+			var banner *models.Banner
+			banner, err = target.RequestContext(sc.ctx)
+			if err == nil && banner != nil {
+				data = banner.ResponseData
+			}
+		}
+		// 中断时被取消的请求不算目标的错误
+		if err != nil && sc.ctx.Err() == nil {
+			log.Printf("Request failed for %s%s: %v", sc.u.Host, rule.Path, err)
+			sc.result.AddError(sc.u.Scheme+"://"+sc.u.Host+rule.Path, string(http.ClassifyError(err)), err)
+		}
+		sc.remember(cacheKey, data)
+	}
+	if sc.detectWaf(data) {
+		// 拦截页不拿来匹配产品,避免把WAF页面识别成应用
+		data = nil
+	}
+	if data != nil && sc.isSoft404(rule, data) {
+		sc.result.Wildcard++
+		data = nil
+	}
+	sc.responses[key] = data
+	return data
+}
+
+// detectWaf 记录响应中识别出的WAF/CDN,返回响应是否为拦截页
+func (sc *scan) detectWaf(data *fingerprints.ResponseData) bool {
+	if len(sc.Waf) == 0 || data == nil {
+		return false
+	}
+	hits, blocked := fingerprints.DetectWaf(sc.Waf, data)
+	for _, hit := range hits {
+		sc.result.AddWaf(hit)
+	}
+	if blocked {
+		sc.blocks++
+		log.Printf("%s 返回了WAF拦截页", data.URL)
+	}
+	return blocked
+}
+
+func (sc *scan) requiresMet(rule *fingerprints.CompiledRule) bool {
+	for _, name := range rule.Requires {
+		if !sc.result.Has(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// infer 根据已识别产品的 implies 推断上级产品,返回是否有新产品
+func (sc *scan) infer() bool {
+	found := false
+	for i := 0; i < len(sc.result.Products); i++ {
+		product := sc.result.Products[i]
+		for _, parent := range sc.implies[strings.ToLower(product.Name)] {
+			if sc.result.Imply(parent, product.Name) {
+				found = true
+			}
+		}
+	}
+	return found
+}
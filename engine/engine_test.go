@@ -0,0 +1,421 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	"PrintRaptor/models"
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// serveTCP 起一个TCP服务,handler 处理每个连接
+func serveTCP(t *testing.T, handler func(net.Conn)) *url.URL {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handler(conn)
+			}()
+		}
+	}()
+	u, _ := url.Parse("tcp://" + ln.Addr().String())
+	return u
+}
+
+func TestScanService(t *testing.T) {
+	rules, err := fingerprints.LoadBuiltinRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := NewScanner(rules, false)
+
+	ssh := serveTCP(t, func(conn net.Conn) {
+		conn.Write([]byte("SSH-2.0-OpenSSH_8.9p1 Ubuntu-3\r\n"))
+	})
+	result := scanner.Scan(ssh)
+	if len(result.Products) != 1 || result.Products[0].Name != "ssh" {
+		t.Fatalf("期望识别出 ssh, 实际 %+v", result.Products)
+	}
+
+	redis := serveTCP(t, func(conn net.Conn) {
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		if line == "PING\r\n" {
+			conn.Write([]byte("+PONG\r\n"))
+		}
+	})
+	result = scanner.Scan(redis)
+	if len(result.Products) != 1 || result.Products[0].Name != "redis" {
+		t.Fatalf("期望识别出 redis, 实际 %+v", result.Products)
+	}
+}
+
+// TestScanServiceRDP 内置 RDP 规则按字节发送 19 字节的 X.224 连接请求,并按字节匹配 TPKT 回应
+func TestScanServiceRDP(t *testing.T) {
+	builtin, err := fingerprints.LoadBuiltinRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rules []fingerprints.CompiledRule
+	for _, rule := range builtin {
+		if rule.Name == "rdp" {
+			rule.Ports = nil // 测试服务不在 3389 端口
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) != 1 {
+		t.Fatalf("内置库中应有一条 rdp 规则, 实际 %d 条", len(rules))
+	}
+	want := []byte{0x03, 0x00, 0x00, 0x13, 0x0e, 0xe0, 0, 0, 0, 0, 0, 0x01, 0, 0x08, 0, 0x03, 0, 0, 0}
+	received := make(chan []byte, 1)
+	rdp := serveTCP(t, func(conn net.Conn) {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		buf := make([]byte, 64)
+		n, _ := io.ReadAtLeast(conn, buf, len(want))
+		received <- buf[:n]
+		conn.Write([]byte{0x03, 0x00, 0x00, 0x13, 0x0e, 0xd0, 0x00, 0x00, 0x12, 0x34, 0x00})
+	})
+	result := NewScanner(rules, false).Scan(rdp)
+	if got := <-received; !bytes.Equal(got, want) {
+		t.Fatalf("payload 应为 19 字节的 X.224 连接请求, 实际 % x", got)
+	}
+	if len(result.Products) != 1 || result.Products[0].Name != "rdp" {
+		t.Fatalf("期望识别出 rdp, 实际 %+v", result.Products)
+	}
+}
+
+// TestScanUnreachable 端口拒绝连接时不重试,结果标记为不可达并记录失败原因
+func TestScanUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	u, _ := url.Parse("http://" + addr)
+	result := NewScanner(nil, true).Scan(u)
+	if !result.Unreachable || len(result.Errors) != 1 || result.Errors[0].Class != "refused" {
+		t.Fatalf("期望不可达且原因为 refused, 实际 %+v", result)
+	}
+}
+
+// TestScanWafBlock 拦截页不参与产品匹配,stop 模式下之后不再请求特殊路径
+func TestScanWafBlock(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		requested = append(requested, r.URL.Path)
+		w.Header().Set("Server", "cloudflare")
+		if r.URL.Path == "/admin" {
+			w.WriteHeader(nethttp.StatusForbidden)
+			w.Write([]byte(`<title>Attention Required! | Cloudflare</title>`))
+			return
+		}
+		w.Write([]byte("<title>home</title>"))
+	}))
+	defer server.Close()
+
+	rule := func(name, path, expr string) fingerprints.CompiledRule {
+		rule, err := fingerprints.LoadRulesFromBytes("test", []byte("- name: "+name+"\n  path: "+path+"\n  expression: '"+expr+"'\n"))
+		if err != nil || len(rule) != 1 {
+			t.Fatalf("规则编译失败: %v", err)
+		}
+		return rule[0]
+	}
+	waf, err := fingerprints.LoadWafRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := NewScanner([]fingerprints.CompiledRule{
+		rule("fake", "/admin", `body="Cloudflare"`),
+		rule("later", "/later", `body="home"`),
+	}, false)
+	scanner.Waf = waf
+	scanner.OnBlock = OnBlockStop
+
+	u, _ := url.Parse(server.URL)
+	result := scanner.Scan(u)
+	if len(result.Products) != 0 {
+		t.Fatalf("拦截页不应命中产品: %+v", result.Products)
+	}
+	if !result.Blocked || len(result.Wafs) != 1 || result.Wafs[0].Name != "Cloudflare" {
+		t.Fatalf("期望识别出 Cloudflare 拦截, 实际 %+v", result.Wafs)
+	}
+	for _, path := range requested {
+		if path == "/later" {
+			t.Fatal("stop 模式下遇到拦截页后不应再请求特殊路径")
+		}
+	}
+}
+
+// TestScanHostInfo 主机的标题、长度和 hash 只取根路径的响应,根路径没有标题时不拿特殊路径的补上
+func TestScanHostInfo(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/admin" {
+			w.Write([]byte("<title>admin</title>login form"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	rules, err := fingerprints.LoadRulesFromBytes("test", []byte("- name: admin\n  path: /admin\n  expression: 'body=\"login\"'\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	result := NewScanner(rules, false).Scan(u)
+	if len(result.Products) != 1 {
+		t.Fatalf("期望命中 admin, 实际 %+v", result.Products)
+	}
+	if result.Title != "" || result.BodyLength != len("ok") {
+		t.Fatalf("主机信息应取自根路径, 实际 title=%q length=%d", result.Title, result.BodyLength)
+	}
+}
+
+// TestScanFastModeSkipsSteps 快速模式跳过多步规则,不拿根路径响应去匹配最后一步的表达式
+func TestScanFastModeSkipsSteps(t *testing.T) {
+	var stepped int32
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/version.txt" {
+			atomic.AddInt32(&stepped, 1)
+		}
+		w.Write([]byte("demo"))
+	}))
+	defer server.Close()
+	data := "- name: demo-chain\n  steps:\n    - path: /\n    - path: /version.txt\n      expression: body=\"demo\"\n"
+	rules, err := fingerprints.LoadRulesFromBytes("test", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	result := NewScanner(rules, true).Scan(u)
+	if len(result.Products) != 0 {
+		t.Fatalf("快速模式不应命中多步规则, 实际 %+v", result.Products)
+	}
+	if atomic.LoadInt32(&stepped) != 0 {
+		t.Fatal("快速模式不应发送多步请求")
+	}
+}
+
+// TestScanRequires requires 的前置产品识别出来后才发包,implies 不发包直接推断,
+// 前置产品不存在或互相依赖时不发包也不会卡住
+func TestScanRequires(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		// 各页面内容差别要足够大,不然会被当成通配响应
+		pages := map[string]string{
+			"/":       "<title>home</title>",
+			"/base":   "<title>base console</title>base framework 1.0",
+			"/module": "<html><body><h1>module</h1>" + strings.Repeat("x", 200) + "</body></html>",
+			"/addon":  `{"addon": true, "items": [1, 2, 3, 4, 5, 6, 7, 8, 9]}`,
+		}
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			w.WriteHeader(nethttp.StatusNotFound)
+			page = "not found"
+		}
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+	// 依赖方写在前面,必须等前置产品命中后的下一轮才请求
+	data := `- name: module
+  path: /module
+  requires: [base]
+  implies: [suite]
+  expression: body="<h1>module</h1>"
+- name: addon
+  path: /addon
+  requires: [suite]
+  expression: body="\"addon\""
+- name: base
+  path: /base
+  expression: body="base framework"
+- name: orphan
+  path: /orphan
+  requires: [missing]
+  expression: body="page"
+- name: loop-a
+  path: /loop-a
+  requires: [loop-b]
+  expression: body="page"
+- name: loop-b
+  path: /loop-b
+  requires: [loop-a]
+  expression: body="page"
+`
+	rules, err := fingerprints.LoadRulesFromBytes("test", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	done := make(chan *models.HostResult, 1)
+	go func() { done <- NewScanner(rules, false).Scan(u) }()
+	var result *models.HostResult
+	select {
+	case result = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("依赖无法满足时扫描不应卡住")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	order := make(map[string]int)
+	for i, path := range requested {
+		if _, ok := order[path]; !ok {
+			order[path] = i
+		}
+	}
+	for _, path := range []string{"/orphan", "/loop-a", "/loop-b", "/suite"} {
+		if _, ok := order[path]; ok {
+			t.Errorf("%s 不应被请求, 实际请求了 %v", path, requested)
+		}
+	}
+	base, okBase := order["/base"]
+	module, okModule := order["/module"]
+	addon, okAddon := order["/addon"]
+	if !okBase || !okModule || !okAddon || base > module || module > addon {
+		t.Fatalf("前置产品命中后才应请求依赖方, 实际顺序 %v", requested)
+	}
+	for _, name := range []string{"base", "module", "suite", "addon"} {
+		if !result.Has(name) {
+			t.Errorf("缺少产品 %s, 实际 %+v", name, result.Products)
+		}
+	}
+	for _, name := range []string{"orphan", "loop-a", "loop-b"} {
+		if result.Has(name) {
+			t.Errorf("依赖不满足的 %s 不应命中", name)
+		}
+	}
+	for _, product := range result.Products {
+		if product.Name == "suite" && (len(product.Hits) != 0 || len(product.ImpliedBy) != 1 || product.ImpliedBy[0] != "module") {
+			t.Errorf("suite 应由 module 推断得出, 实际 %+v", product)
+		}
+	}
+}
+
+// TestScanContextCanceled 取消后不再发请求,结果标记为中断
+func TestScanContextCanceled(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	u, _ := url.Parse(server.URL)
+	result := NewScanner(nil, true).ScanContext(ctx, u)
+	if !result.Interrupted || result.Unreachable || atomic.LoadInt32(&requests) != 0 {
+		t.Fatalf("期望中断且不发请求, 实际 %+v, 请求 %d 次", result, requests)
+	}
+}
+
+// TestResumeVhost 续扫时中断前已拿到的响应直接复用,不再重发,结果和完整扫描一致
+func TestResumeVhost(t *testing.T) {
+	var mu sync.Mutex
+	requested := make(map[string]int)
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		mu.Lock()
+		requested[r.URL.Path]++
+		mu.Unlock()
+		pages := map[string]string{
+			"/":      "<title>home</title>",
+			"/done":  "<title>done console</title>done framework 1.0",
+			"/later": `{"later": true, "items": [1, 2, 3, 4, 5, 6, 7, 8, 9]}`,
+		}
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			w.WriteHeader(nethttp.StatusNotFound)
+			page = "not found"
+		}
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+	data := `- name: done
+  path: /done
+  expression: body="done framework"
+- name: later
+  path: /later
+  expression: body="\"later\""
+`
+	rules, err := fingerprints.LoadRulesFromBytes("test", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	scanner := NewScanner(rules, false)
+	_, responses := scanner.ResumeVhost(context.Background(), u, "", nil)
+	// 模拟 /later 还没请求就被中断
+	resumed := make(map[string]*fingerprints.ResponseData)
+	for key, data := range responses {
+		if !strings.Contains(key, "/later") {
+			resumed[key] = data
+		}
+	}
+	if len(resumed) == len(responses) || len(resumed) == 0 {
+		t.Fatalf("响应记录不符合预期: %v", responses)
+	}
+
+	mu.Lock()
+	requested = make(map[string]int)
+	mu.Unlock()
+	result, _ := scanner.ResumeVhost(context.Background(), u, "", resumed)
+	mu.Lock()
+	defer mu.Unlock()
+	if requested["/"] != 0 || requested["/done"] != 0 {
+		t.Errorf("已拿到的响应不应重发, 实际请求 %v", requested)
+	}
+	if requested["/later"] != 1 {
+		t.Errorf("中断前没请求的规则应补发, 实际请求 %v", requested)
+	}
+	if result.Interrupted || !result.Has("done") || !result.Has("later") {
+		t.Errorf("续扫结果不完整: %+v", result)
+	}
+}
+
+// TestBlockBackoff 退避时间逐次翻倍但有上限,移位不会溢出;等待可以被 ctx 取消
+func TestBlockBackoff(t *testing.T) {
+	base := 3 * time.Second
+	if got := blockBackoff(base, 1); got != base {
+		t.Fatalf("首次应等待 %v, 实际 %v", base, got)
+	}
+	if got := blockBackoff(base, 3); got != 4*base {
+		t.Fatalf("第三次应等待 %v, 实际 %v", 4*base, got)
+	}
+	for _, blocks := range []int{20, 64, 100} {
+		if got := blockBackoff(base, blocks); got != maxBlockBackoff {
+			t.Fatalf("第 %d 次应等待上限 %v, 实际 %v", blocks, maxBlockBackoff, got)
+		}
+	}
+	if got := blockBackoff(0, 5); got != 0 {
+		t.Fatalf("未配置退避时不等待, 实际 %v", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	sleep(ctx, time.Minute)
+	if time.Since(start) > time.Second {
+		t.Fatal("ctx 取消后应立即返回")
+	}
+}
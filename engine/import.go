@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	"PrintRaptor/http"
+	"PrintRaptor/models"
+	"context"
+	"net/url"
+)
+
+/*
+导入流量的匹配
+HAR、Burp 导出等流量按目标(协议+主机)分组,每条响应按请求方法和路径对应到规则的请求,
+再按离线模式跑一遍完整的识别,结果与在线扫描一致;流量里没有的请求按失败处理
+*/
+
+// ScanImported 对导入的流量逐个目标识别,按目标首次出现的顺序返回结果
+func (s *Scanner) ScanImported(traffic []fingerprints.ImportedResponse) []*models.HostResult {
+	var order []*url.URL
+	groups := make(map[string]map[string]*fingerprints.ResponseData)
+	icons := make(map[string]string)
+	for _, item := range traffic {
+		u, err := url.Parse(item.Data.URL)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		target := &url.URL{Scheme: u.Scheme, Host: u.Host}
+		responses, ok := groups[target.String()]
+		if !ok {
+			responses = make(map[string]*fingerprints.ResponseData)
+			groups[target.String()] = responses
+			order = append(order, target)
+		}
+		rule := &fingerprints.CompiledRule{RuleConfig: fingerprints.RuleConfig{Path: u.RequestURI()}}
+		rule.Method = item.Method
+		// 同一请求出现多次时以第一次为准,与在线扫描只请求一次一致;
+		// 带请求体的按请求体区分,同时第一条也供没写请求体的规则使用
+		keys := []string{requestKey(rule)}
+		if len(item.Body) > 0 {
+			rule.Body = string(item.Body)
+			keys = append(keys, requestKey(rule))
+		}
+		for _, key := range keys {
+			if responses[key] == nil {
+				responses[key] = item.Data
+			}
+		}
+		if u.Path == "/favicon.ico" && item.Data.Status == 200 && icons[target.String()] == "" {
+			icons[target.String()] = http.Encode([]byte(item.Data.Body))
+		}
+	}
+
+	results := make([]*models.HostResult, 0, len(order))
+	for _, target := range order {
+		responses := groups[target.String()]
+		// 在线扫描时每个响应都会带上 favicon 的 hash
+		if hash := icons[target.String()]; hash != "" {
+			for _, data := range responses {
+				if data.Hash == "" {
+					data.Hash = hash
+				}
+			}
+		}
+		sc := s.newScan(context.Background(), target)
+		sc.offline = true
+		sc.cached = responses
+		results = append(results, sc.run())
+	}
+	return results
+}
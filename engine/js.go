@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	"log"
+	"strings"
+)
+
+/*
+脚本分析
+精准模式下请求根路径引用的同源脚本和它们的 source map,拼成根路径响应的 js 字段,
+通用规则里的 js 条件就是在这份内容上匹配的。没有规则用到 js 时不发这些请求。
+请求都发往目标本身(见 fetch),跨域的脚本和 source map 跳过
+*/
+
+// defaultMaxScripts 每个目标默认最多请求的脚本数
+const defaultMaxScripts = 10
+
+const scriptName = "script"
+
+// scriptRule 请求脚本和 source map 使用的占位规则
+func scriptRule(path string) *fingerprints.CompiledRule {
+	return &fingerprints.CompiledRule{RuleConfig: fingerprints.RuleConfig{Name: scriptName, Path: path}}
+}
+
+// analyzeJS 请求根路径引用的脚本,结果写入 root.JS
+func (sc *scan) analyzeJS(root *fingerprints.ResponseData) {
+	if !sc.JSAnalysis || !sc.needJS || sc.FastMode || root == nil {
+		return
+	}
+	limit := sc.MaxScripts
+	if limit <= 0 {
+		limit = defaultMaxScripts
+	}
+	var sb strings.Builder
+	base := sc.baseURL(root)
+	scripts := 0
+	for _, src := range fingerprints.ScriptSources(root.Body) {
+		link, ok := sc.sameOrigin(base, src)
+		if !ok {
+			continue
+		}
+		if scripts >= limit || sc.ctx.Err() != nil {
+			break
+		}
+		scripts++
+		script := sc.fetch(scriptRule(link))
+		if script == nil {
+			continue
+		}
+		sb.WriteString(script.Body)
+		sb.WriteByte('\n')
+		sb.WriteString(sc.sourceMap(script))
+	}
+	// 离线时缓存里可能没有脚本,保留缓存中已有的 js 字段
+	if sb.Len() > 0 {
+		root.JS = sb.String()
+	}
+}
+
+// sourceMap 脚本的 source map 摘要,内联的直接解码,同源的再请求一次
+func (sc *scan) sourceMap(script *fingerprints.ResponseData) string {
+	ref := fingerprints.SourceMapURL(script.Body, script.Headers)
+	if ref == "" {
+		return ""
+	}
+	var raw []byte
+	if strings.HasPrefix(ref, "data:") {
+		var err error
+		if raw, err = fingerprints.DecodeDataURL(ref); err != nil {
+			log.Printf("%s 的内联 source map 解码失败: %v", script.URL, err)
+			return ""
+		}
+	} else {
+		link, ok := sc.sameOrigin(sc.baseURL(script), ref)
+		if !ok {
+			return ""
+		}
+		data := sc.fetch(scriptRule(link))
+		if data == nil {
+			return ""
+		}
+		raw = []byte(data.Body)
+	}
+	sm, err := fingerprints.ParseSourceMap(raw)
+	if err != nil {
+		log.Printf("%s: %v", script.URL, err)
+		return ""
+	}
+	return sm.Summary()
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// TestScanJS js 条件在引用脚本及其 source map 上命中;没有规则用到 js 时不请求脚本
+func TestScanJS(t *testing.T) {
+	var mu sync.Mutex
+	requested := make(map[string]bool)
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		mu.Lock()
+		requested[r.URL.Path] = true
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<div id="app"></div><script src="/assets/app.js"></script><script src="https://cdn.example/x.js"></script>`))
+		case "/assets/app.js":
+			w.Write([]byte("!function(){new Foo()}();\n//# sourceMappingURL=app.js.map"))
+		case "/assets/app.js.map":
+			w.Write([]byte(`{"version":3,"sources":["webpack:///node_modules/vue/dist/vue.runtime.esm.js"],
+"sourcesContent":["/*!\n * Vue.js v2.6.14\n */"]}`))
+		default:
+			nethttp.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	rules, err := fingerprints.LoadRulesFromBytes("test", []byte(`
+- name: vue
+  expression: js="node_modules/vue/" && js="Vue.js v2"
+- name: foo
+  expression: body="new Foo"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := NewScanner(rules, false).Scan(u)
+	if !result.Has("vue") || result.Has("foo") {
+		t.Fatalf("脚本识别结果错误: %+v", result.Products)
+	}
+
+	mu.Lock()
+	requested = make(map[string]bool)
+	mu.Unlock()
+	rules, err = fingerprints.LoadRulesFromBytes("test", []byte(`
+- name: foo
+  expression: body="new Foo"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	NewScanner(rules, false).Scan(u)
+	if requested["/assets/app.js"] {
+		t.Fatal("没有规则用到 js 时不应请求脚本")
+	}
+}
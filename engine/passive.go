@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	"PrintRaptor/models"
+	"context"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+/*
+被动识别
+代理转发的每个响应放进队列,由后台 goroutine 按主机聚合匹配,不影响转发速度:
+根路径规则对该主机的每个响应求值,特殊路径规则只对方法和路径一致的响应求值,
+requires / implies 与主动扫描一致;多步请求和非HTTP规则在被动模式下不生效
+*/
+
+// passiveQueueSize 后台匹配跟不上时最多积压的响应数,超出的直接丢弃
+const passiveQueueSize = 1024
+
+type passiveItem struct {
+	method string
+	data   *fingerprints.ResponseData
+}
+
+// Passive 被动识别的聚合结果
+type Passive struct {
+	*Scanner
+	// Report 某个主机新识别出产品时调用,在后台 goroutine 中执行
+	Report func(host, product string)
+
+	mu    sync.Mutex
+	hosts map[string]*models.HostResult
+	order []string
+	queue chan passiveItem
+	done  chan struct{}
+
+	// closeMu 保护 closed: 关闭后仍在运行的代理 handler 提交响应时直接丢弃,不能向已关闭的队列发送
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+// NewPassive 创建被动识别并启动后台匹配
+func NewPassive(s *Scanner, report func(host, product string)) *Passive {
+	p := &Passive{
+		Scanner: s,
+		Report:  report,
+		hosts:   make(map[string]*models.HostResult),
+		queue:   make(chan passiveItem, passiveQueueSize),
+		done:    make(chan struct{}),
+	}
+	go p.work()
+	return p
+}
+
+// Observe 提交一个响应,不阻塞调用方
+func (p *Passive) Observe(method string, data *fingerprints.ResponseData) {
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+	if p.closed {
+		return
+	}
+	select {
+	case p.queue <- passiveItem{method: method, data: data}:
+	default:
+		log.Printf("被动识别队列已满,丢弃响应 %s", data.URL)
+	}
+}
+
+// Close 停止接收响应,等待队列中的响应匹配完
+func (p *Passive) Close() {
+	p.closeMu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.closeMu.Unlock()
+	<-p.done
+}
+
+func (p *Passive) work() {
+	defer close(p.done)
+	for item := range p.queue {
+		p.match(item.method, item.data)
+	}
+}
+
+func (p *Passive) match(method string, data *fingerprints.ResponseData) {
+	u, err := url.Parse(data.URL)
+	if err != nil || data.Host == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result, ok := p.hosts[data.Host]
+	if !ok {
+		result = models.NewHostResult(data.Host)
+		p.hosts[data.Host] = result
+		p.order = append(p.order, data.Host)
+	}
+	sc := &scan{Scanner: p.Scanner, ctx: context.Background(), u: u, result: result}
+	if len(p.Waf) > 0 {
+		if sc.detectWaf(data) {
+			return
+		}
+	}
+	before := make(map[string]bool, len(result.Products))
+	for _, product := range result.Products {
+		before[strings.ToLower(product.Name)] = true
+	}
+
+	observed := &fingerprints.CompiledRule{RuleConfig: fingerprints.RuleConfig{Path: u.RequestURI()}}
+	observed.Method = method
+	key := requestKey(observed)
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.IsServiceRule() || len(rule.Steps) > 0 || !sc.requiresMet(rule) {
+			continue
+		}
+		if isRootRule(rule) || requestKey(rule) == key {
+			result.Add(&models.Banner{ResponseData: data, CompiledRule: rule})
+		}
+	}
+	for sc.infer() {
+	}
+
+	if p.Report == nil {
+		return
+	}
+	for _, product := range result.Products {
+		if !before[strings.ToLower(product.Name)] {
+			p.Report(data.Host, product.Name)
+		}
+	}
+}
+
+// Results 各主机当前的聚合结果,按首次出现的顺序
+func (p *Passive) Results() []*models.HostResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	results := make([]*models.HostResult, 0, len(p.order))
+	for _, host := range p.order {
+		result := p.hosts[host]
+		result.Finish()
+		results = append(results, result)
+	}
+	return results
+}
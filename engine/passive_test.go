@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	"PrintRaptor/http"
+	"compress/gzip"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// TestPassiveProxy 经过被动代理的流量照常返回给客户端,并在后台识别出产品
+func TestPassiveProxy(t *testing.T) {
+	backend := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch r.URL.Path {
+		case "/":
+			// 浏览器声明了 gzip 时响应原样压缩转发
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			gz.Write([]byte("<title>Home</title> powered by ThinkPHP"))
+			gz.Close()
+		case "/manager/html":
+			w.WriteHeader(nethttp.StatusUnauthorized)
+			w.Write([]byte("Tomcat Manager Application"))
+		}
+	}))
+	defer backend.Close()
+
+	rules, err := fingerprints.LoadRulesFromBytes("test", []byte(`
+- name: thinkphp
+  expression: body="ThinkPHP"
+- name: tomcat-manager
+  path: /manager/html
+  expression: body="Tomcat Manager"
+  implies: [tomcat]
+- name: struts
+  path: /struts/webconsole.html
+  expression: body="Tomcat"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var reported []string
+	passive := NewPassive(NewScanner(rules, false), func(host, product string) {
+		mu.Lock()
+		reported = append(reported, product)
+		mu.Unlock()
+	})
+	proxy := httptest.NewServer(&http.PassiveProxy{OnResponse: passive.Observe})
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	client := &nethttp.Client{Transport: &nethttp.Transport{Proxy: nethttp.ProxyURL(proxyURL)}}
+	for _, path := range []string{"/", "/manager/html"} {
+		resp, err := client.Get(backend.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if path == "/" && string(body) != "<title>Home</title> powered by ThinkPHP" {
+			t.Fatalf("代理转发的响应被改动: %q", body)
+		}
+	}
+	passive.Close()
+
+	results := passive.Results()
+	if len(results) != 1 {
+		t.Fatalf("期望 1 个主机, 实际 %d", len(results))
+	}
+	result := results[0]
+	if !result.Has("thinkphp") || !result.Has("tomcat-manager") || !result.Has("tomcat") || result.Has("struts") {
+		t.Fatalf("被动识别结果错误: %+v", result.Products)
+	}
+	if len(reported) != 3 {
+		t.Fatalf("期望实时报告 3 个产品, 实际 %v", reported)
+	}
+}
+
+// TestPassiveObserveAfterClose 关闭时仍在运行的代理 handler 继续提交响应,不能 panic
+func TestPassiveObserveAfterClose(t *testing.T) {
+	passive := NewPassive(NewScanner(nil, false), nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				passive.Observe("GET", &fingerprints.ResponseData{Host: "127.0.0.1", URL: "http://127.0.0.1/"})
+			}
+		}()
+	}
+	passive.Close()
+	wg.Wait()
+	passive.Close() // 重复关闭也不出错
+}
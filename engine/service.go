@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	"PrintRaptor/http"
+	"log"
+	"strconv"
+)
+
+// applicable HTTP目标只用HTTP规则,tcp/udp目标只用对应协议且端口匹配的banner规则
+func (sc *scan) applicable(rule *fingerprints.CompiledRule) bool {
+	if !fingerprints.IsServiceProtocol(sc.u.Scheme) {
+		return !rule.IsServiceRule()
+	}
+	if rule.Protocol != sc.u.Scheme {
+		return false
+	}
+	port, _ := strconv.Atoi(sc.u.Port())
+	return rule.MatchPort(port)
+}
+
+// probe banner探测,payload 相同的规则共用一次探测结果
+func (sc *scan) probe(rule *fingerprints.CompiledRule) *fingerprints.ResponseData {
+	key := "PROBE\n" + rule.Payload
+	if data, ok := sc.responses[key]; ok {
+		return data
+	}
+	if sc.ctx.Err() != nil {
+		return nil
+	}
+	if sc.offline {
+		sc.responses[key] = sc.cached[key]
+		return sc.responses[key]
+	}
+	if data, ok := sc.resumed[key]; ok {
+		sc.remember(key, data)
+		sc.responses[key] = data
+		return data
+	}
+	var data *fingerprints.ResponseData
+	target, err := http.NewTarget(sc.u, rule)
+	if err == nil {
+		banner, probeErr := target.ProbeContext(sc.ctx)
+		err = probeErr
+		if banner != nil && banner.ResponseData.Banner != "" {
+			data = banner.ResponseData
+		}
+	}
+	if err != nil && sc.ctx.Err() == nil {
+		log.Printf("Probe failed for %s: %v", sc.u.String(), err)
+		sc.result.AddError(sc.u.String(), string(http.ClassifyError(err)), err)
+	}
+	sc.remember(key, data)
+	sc.responses[key] = data
+	return data
+}
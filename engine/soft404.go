@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+/*
+soft-404 / 通配响应检测
+很多站点对任意路径都返回 200 和同一个页面,特殊路径规则是否命中就变成了看首页长什么样。
+精准模式下第一次请求特殊路径前,先请求一个随机路径作为基线,
+之后每个特殊路径的响应与基线比较: 状态码相同、长度相近、内容相似的视为与不存在的路径无法区分,不参与匹配
+*/
+
+const (
+	// soft404LengthRatio 长度比例低于该值时认为不同
+	soft404LengthRatio = 0.9
+	// soft404Similarity 内容相似度(词集合的 Jaccard 系数)不低于该值时认为相同
+	soft404Similarity = 0.9
+)
+
+const baselineName = "baseline"
+
+// baselineRule 随机路径,每次扫描生成一个
+func baselineRule() *fingerprints.CompiledRule {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return &fingerprints.CompiledRule{RuleConfig: fingerprints.RuleConfig{Name: baselineName, Path: "/" + hex.EncodeToString(buf) + ".html"}}
+}
+
+// isSoft404 特殊路径的响应是否与随机路径的基线无法区分,第一次调用时请求基线
+func (sc *scan) isSoft404(rule *fingerprints.CompiledRule, data *fingerprints.ResponseData) bool {
+	if !sc.Soft404 || rule.Raw != "" || isRootRule(rule) {
+		return false
+	}
+	if sc.baselinePath == "" {
+		baseline := baselineRule()
+		sc.baselinePath = baseline.Path
+		sc.baseline = sc.fetch(baseline)
+		// 离线模式下基线来自缓存,路径以缓存中记录的为准
+		if sc.baseline != nil {
+			if u, err := url.Parse(sc.baseline.URL); err == nil && u.Path != "" {
+				sc.baselinePath = u.Path
+			}
+		}
+	}
+	if sc.baseline == nil || rule.Path == sc.baselinePath {
+		return false
+	}
+	return sameResponse(sc.baseline, data, sc.baselinePath, rule.Path)
+}
+
+// sameResponse 比较两个响应,页面中回显的请求路径先去掉再比较
+func sameResponse(baseline, data *fingerprints.ResponseData, baselinePath, path string) bool {
+	if baseline.Status != data.Status {
+		return false
+	}
+	a := strings.ReplaceAll(baseline.Body, baselinePath, "")
+	b := strings.ReplaceAll(data.Body, path, "")
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	short, long := len(a), len(b)
+	if short > long {
+		short, long = long, short
+	}
+	if float64(short)/float64(long) < soft404LengthRatio {
+		return false
+	}
+	return similarity(a, b) >= soft404Similarity
+}
+
+// similarity 两段文本词集合的 Jaccard 系数
+func similarity(a, b string) float64 {
+	split := func(s string) map[string]bool {
+		words := make(map[string]bool)
+		for _, w := range strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			words[w] = true
+		}
+		return words
+	}
+	wa, wb := split(a), split(b)
+	if len(wa) == 0 && len(wb) == 0 {
+		return 1
+	}
+	common := 0
+	for w := range wa {
+		if wb[w] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(wa)+len(wb)-common)
+}
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestSameResponse(t *testing.T) {
+	page := func(status int, body string) *fingerprints.ResponseData {
+		return &fingerprints.ResponseData{Status: status, Body: body}
+	}
+	home := "<html><title>Welcome</title><body>shop home page with many products listed here</body></html>"
+	if !sameResponse(page(200, home), page(200, home), "/a1b2", "/manager/html") {
+		t.Error("相同页面应视为无法区分")
+	}
+	echo := "<p>page %s not found, go back to the home page</p>"
+	if !sameResponse(page(404, fmt.Sprintf(echo, "/a1b2c3d4")), page(404, fmt.Sprintf(echo, "/login")), "/a1b2c3d4", "/login") {
+		t.Error("回显请求路径的 404 页面应视为无法区分")
+	}
+	if sameResponse(page(200, home), page(404, home), "/a", "/b") {
+		t.Error("状态码不同应视为不同")
+	}
+	if sameResponse(page(200, home), page(200, "<title>Tomcat Manager</title> login required for the manager application"), "/a", "/b") {
+		t.Error("内容不同应视为不同")
+	}
+}
+
+// TestScanSoft404 任意路径返回首页的站点,特殊路径规则不能靠首页内容命中
+func TestScanSoft404(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/real" {
+			w.Write([]byte("<title>Real Admin Console</title> sign in to manage the cluster"))
+			return
+		}
+		w.Write([]byte("<title>home</title> welcome to the home page of this site"))
+	}))
+	defer server.Close()
+
+	rules, err := fingerprints.LoadRulesFromBytes("test", []byte(`
+- name: fake
+  path: /admin
+  expression: body="home page"
+- name: real
+  path: /real
+  expression: body="Admin Console"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	result := NewScanner(rules, false).Scan(u)
+	if len(result.Products) != 1 || result.Products[0].Name != "real" {
+		t.Fatalf("期望只识别出 real, 实际 %+v", result.Products)
+	}
+	if result.Wildcard != 1 {
+		t.Fatalf("期望忽略 1 个通配响应, 实际 %d", result.Wildcard)
+	}
+}
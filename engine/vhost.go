@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	"PrintRaptor/http"
+	"PrintRaptor/models"
+	"context"
+	"log"
+	"strings"
+)
+
+/*
+虚拟主机
+同一个IP按 Host 头返回不同的应用时,每个候选主机名单独扫描一遍(见 ScanVhost),结果分开输出。
+候选主机名依次取自目标文件、目标的 TLS 证书和 IP 的反向解析
+*/
+
+// defaultMaxVhosts 每个目标默认最多尝试的虚拟主机数
+const defaultMaxVhosts = 10
+
+// Vhosts 目标的候选虚拟主机名,去重后最多 MaxVhosts 个,不含目标自身的主机名
+func (s *Scanner) Vhosts(ctx context.Context, target *models.Target) []string {
+	u := target.URL
+	if fingerprints.IsServiceProtocol(u.Scheme) {
+		return nil
+	}
+	limit := s.MaxVhosts
+	if limit <= 0 {
+		limit = defaultMaxVhosts
+	}
+	var vhosts []string
+	add := func(names []string) {
+		for _, name := range names {
+			name = strings.ToLower(name)
+			if len(vhosts) < limit && name != strings.ToLower(u.Hostname()) && !contains(vhosts, name) {
+				vhosts = append(vhosts, name)
+			}
+		}
+	}
+	add(target.Vhosts)
+	if s.VhostFromCert && u.Scheme == "https" {
+		names, err := http.CertNames(ctx, u)
+		if err != nil {
+			log.Printf("读取 %s 的证书失败: %v", u.Host, err)
+		}
+		add(names)
+	}
+	if s.VhostReverseDNS {
+		add(http.ReverseDNS(u.Hostname()))
+	}
+	return vhosts
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"PrintRaptor/fingerprints"
+	"PrintRaptor/models"
+	"context"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// TestScanVhost 同一地址按 Host 头和 SNI 返回不同应用,每个虚拟主机的结果单独输出
+func TestScanVhost(t *testing.T) {
+	handler := nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path != "/" {
+			nethttp.NotFound(w, r)
+			return
+		}
+		// 目标带端口时 Host 头也带端口;https 时还要求 SNI 与 Host 一致
+		host, _, _ := net.SplitHostPort(r.Host)
+		if host == "jenkins.example" && (r.TLS == nil || r.TLS.ServerName == "jenkins.example") {
+			w.Write([]byte("<title>Dashboard [Jenkins]</title>"))
+			return
+		}
+		w.Write([]byte("Welcome to nginx!"))
+	})
+	rules, err := fingerprints.LoadRulesFromBytes("test", []byte(`
+- name: jenkins
+  expression: body="[Jenkins]"
+- name: nginx
+  expression: body="Welcome to nginx"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := NewScanner(rules, false)
+
+	for _, server := range []*httptest.Server{httptest.NewServer(handler), httptest.NewTLSServer(handler)} {
+		defer server.Close()
+		u, _ := url.Parse(server.URL)
+		result := scanner.Scan(u)
+		if !result.Has("nginx") || result.Has("jenkins") || result.Vhost != "" {
+			t.Fatalf("%s 默认主机识别错误: %+v", u.Scheme, result.Products)
+		}
+		result = scanner.ScanVhost(context.Background(), u, "jenkins.example")
+		if !result.Has("jenkins") || result.Has("nginx") || result.Vhost != "jenkins.example" {
+			t.Fatalf("%s 虚拟主机识别错误: %+v", u.Scheme, result.Products)
+		}
+	}
+}
+
+// TestVhosts 候选主机名去重并排除目标自身,开启后从证书中补充,通配符域名跳过
+func TestVhosts(t *testing.T) {
+	server := httptest.NewTLSServer(nethttp.NotFoundHandler())
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	scanner := NewScanner(nil, false)
+	target := &models.Target{URL: u, Vhosts: []string{"A.example", "a.example", "127.0.0.1"}}
+
+	if got := scanner.Vhosts(context.Background(), target); !reflect.DeepEqual(got, []string{"a.example"}) {
+		t.Fatalf("候选主机名错误: %v", got)
+	}
+	// httptest 的证书 SAN 为 example.com 和 IP
+	scanner.VhostFromCert = true
+	if got := scanner.Vhosts(context.Background(), target); !reflect.DeepEqual(got, []string{"a.example", "example.com"}) {
+		t.Fatalf("证书中的主机名错误: %v", got)
+	}
+	scanner.MaxVhosts = 1
+	if got := scanner.Vhosts(context.Background(), target); len(got) != 1 {
+		t.Fatalf("应限制为 1 个, 实际 %v", got)
+	}
+	service, _ := url.Parse("tcp://127.0.0.1:22")
+	if got := scanner.Vhosts(context.Background(), &models.Target{URL: service, Vhosts: []string{"a.example"}}); got != nil {
+		t.Fatalf("非HTTP服务不做虚拟主机探测: %v", got)
+	}
+}
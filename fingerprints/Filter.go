@@ -0,0 +1,153 @@
+package fingerprints
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+/*
+加载时的规则过滤,比如只跑 "OA系统" 或者高 rank 的规则
+名字、标签、路径都支持通配符(* ?),以 re: 开头时按正则处理,均不区分大小写
+*/
+
+// RuleFilter 规则过滤条件,为空的条件不生效
+type RuleFilter struct {
+	IncludeTags  []string `yaml:"IncludeTags"`
+	ExcludeTags  []string `yaml:"ExcludeTags"`
+	MinRank      int      `yaml:"MinRank"` // 0 表示不限制
+	MaxRank      int      `yaml:"MaxRank"` // 0 表示不限制
+	IncludeNames []string `yaml:"IncludeNames"`
+	ExcludeNames []string `yaml:"ExcludeNames"`
+	IncludePaths []string `yaml:"IncludePaths"`
+	ExcludePaths []string `yaml:"ExcludePaths"`
+
+	compiled map[string][]*regexp.Regexp
+}
+
+var (
+	activeFilter *RuleFilter
+	filterMu     sync.RWMutex
+)
+
+// SetRuleFilter 设置加载规则时使用的过滤条件,传 nil 取消过滤
+// 之后的 LoadRules / LoadRulesFromFile / LoadBuiltinRules / LoadLibrary 都会应用它
+func SetRuleFilter(filter *RuleFilter) error {
+	if filter != nil {
+		if err := filter.compile(); err != nil {
+			return err
+		}
+		if filter.IsEmpty() {
+			filter = nil
+		}
+	}
+	filterMu.Lock()
+	activeFilter = filter
+	filterMu.Unlock()
+	return nil
+}
+
+func currentFilter() *RuleFilter {
+	filterMu.RLock()
+	defer filterMu.RUnlock()
+	return activeFilter
+}
+
+// IsEmpty 没有任何过滤条件
+func (f *RuleFilter) IsEmpty() bool {
+	return len(f.IncludeTags) == 0 && len(f.ExcludeTags) == 0 && f.MinRank == 0 && f.MaxRank == 0 &&
+		len(f.IncludeNames) == 0 && len(f.ExcludeNames) == 0 && len(f.IncludePaths) == 0 && len(f.ExcludePaths) == 0
+}
+
+func (f *RuleFilter) compile() error {
+	f.compiled = make(map[string][]*regexp.Regexp)
+	groups := map[string][]string{
+		"IncludeTags": f.IncludeTags, "ExcludeTags": f.ExcludeTags,
+		"IncludeNames": f.IncludeNames, "ExcludeNames": f.ExcludeNames,
+		"IncludePaths": f.IncludePaths, "ExcludePaths": f.ExcludePaths,
+	}
+	for key, patterns := range groups {
+		for _, pattern := range patterns {
+			re, err := compilePattern(pattern)
+			if err != nil {
+				return fmt.Errorf("规则过滤条件 %s 中的 '%s' 无效: %w", key, pattern, err)
+			}
+			f.compiled[key] = append(f.compiled[key], re)
+		}
+	}
+	return nil
+}
+
+// compilePattern 通配符转正则,re: 前缀直接当正则
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(pattern, "re:") {
+		return regexp.Compile("(?i)" + strings.TrimPrefix(pattern, "re:"))
+	}
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.Compile("(?i)^" + expr + "$")
+}
+
+// Match 判断规则是否保留
+func (f *RuleFilter) Match(rule *RuleConfig) bool {
+	if f.compiled == nil {
+		if err := f.compile(); err != nil {
+			return false
+		}
+	}
+	// 没写 rank 的规则按默认值比较,与计算置信度时一致
+	rank := rule.EffectiveRank()
+	if f.MinRank != 0 && rank < f.MinRank {
+		return false
+	}
+	if f.MaxRank != 0 && rank > f.MaxRank {
+		return false
+	}
+	tags := splitTags(rule.Tag)
+	if !f.pass("IncludeTags", "ExcludeTags", tags...) {
+		return false
+	}
+	if !f.pass("IncludeNames", "ExcludeNames", rule.Name) {
+		return false
+	}
+	path := rule.Path
+	if path == "" {
+		path = "/"
+	}
+	return f.pass("IncludePaths", "ExcludePaths", path)
+}
+
+// pass 任一值命中排除条件即淘汰;有包含条件时至少要有一个值命中
+func (f *RuleFilter) pass(include, exclude string, values ...string) bool {
+	for _, re := range f.compiled[exclude] {
+		for _, v := range values {
+			if re.MatchString(v) {
+				return false
+			}
+		}
+	}
+	if len(f.compiled[include]) == 0 {
+		return true
+	}
+	for _, re := range f.compiled[include] {
+		for _, v := range values {
+			if re.MatchString(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitTags 一条规则的 tag 可以用逗号写多个
+func splitTags(tag string) []string {
+	var tags []string
+	for _, t := range strings.FieldsFunc(tag, func(r rune) bool { return r == ',' || r == '，' }) {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,221 @@
+package fingerprints
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+导入已有的流量
+支持 HAR、Burp 导出的 XML(Save items)以及原始HTTP响应文件,
+统一转换成 ResponseData,交给扫描引擎按离线模式匹配,结果与在线扫描一致
+*/
+
+// ImportedResponse 导入的一条流量
+type ImportedResponse struct {
+	Method string // 请求方法,用于对应到规则的请求
+	Body   []byte // 请求体,同一路径的多个POST按请求体区分
+	Data   *ResponseData
+}
+
+// LoadTrafficFile 按内容识别格式并读取流量文件
+func LoadTrafficFile(path string) ([]ImportedResponse, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取流量文件失败: %w", err)
+	}
+	trimmed := bytes.TrimLeft(data, "\ufeff\r\n\t ")
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return LoadHAR(data)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return LoadBurpXML(data)
+	default:
+		resp, err := LoadRawTraffic(data, filepath.Base(path))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return []ImportedResponse{resp}, nil
+	}
+}
+
+// harFile HAR 中用到的字段
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method   string `json:"method"`
+				URL      string `json:"url"`
+				PostData struct {
+					Text string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+			Response struct {
+				Status  int `json:"status"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				Content struct {
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"content"`
+			} `json:"response"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// LoadHAR 读取浏览器或抓包工具导出的 HAR
+func LoadHAR(data []byte) ([]ImportedResponse, error) {
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("解析HAR失败: %w", err)
+	}
+	var responses []ImportedResponse
+	for _, entry := range har.Log.Entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil || u.Host == "" || entry.Response.Status == 0 {
+			continue
+		}
+		header := http.Header{}
+		for _, h := range entry.Response.Headers {
+			// HTTP/2 的伪头部不是真正的响应头
+			if !strings.HasPrefix(h.Name, ":") {
+				header.Add(h.Name, h.Value)
+			}
+		}
+		body := []byte(entry.Response.Content.Text)
+		if entry.Response.Content.Encoding == "base64" {
+			if body, err = base64.StdEncoding.DecodeString(entry.Response.Content.Text); err != nil {
+				continue
+			}
+		}
+		imported := newImported(entry.Request.Method, u, entry.Response.Status, header, body)
+		if text := entry.Request.PostData.Text; text != "" {
+			imported.Body = []byte(text)
+		}
+		responses = append(responses, imported)
+	}
+	return responses, nil
+}
+
+// burpItems Burp "Save items" 导出的XML
+type burpItems struct {
+	Items []struct {
+		URL     string `xml:"url"`
+		Method  string `xml:"method"`
+		Status  int    `xml:"status"`
+		Request struct {
+			Base64 bool   `xml:"base64,attr"`
+			Value  string `xml:",chardata"`
+		} `xml:"request"`
+		Response struct {
+			Base64 bool   `xml:"base64,attr"`
+			Value  string `xml:",chardata"`
+		} `xml:"response"`
+	} `xml:"item"`
+}
+
+// LoadBurpXML 读取 Burp 导出的XML,响应是完整的原始响应,可能经过base64编码
+func LoadBurpXML(data []byte) ([]ImportedResponse, error) {
+	var items burpItems
+	if err := xml.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("解析Burp XML失败: %w", err)
+	}
+	var responses []ImportedResponse
+	for _, item := range items.Items {
+		u, err := url.Parse(item.URL)
+		if err != nil || u.Host == "" || item.Response.Value == "" {
+			continue
+		}
+		raw := []byte(item.Response.Value)
+		if item.Response.Base64 {
+			if raw, err = base64.StdEncoding.DecodeString(item.Response.Value); err != nil {
+				continue
+			}
+		}
+		resp, err := ParseRawResponse(raw, u.Host)
+		if err != nil {
+			continue
+		}
+		resp.URL = u.String()
+		imported := ImportedResponse{Method: methodOrGet(item.Method), Data: resp}
+		if request := []byte(item.Request.Value); len(request) > 0 {
+			if item.Request.Base64 {
+				request, _ = base64.StdEncoding.DecodeString(item.Request.Value)
+			}
+			imported.Body = rawRequestBody(request)
+		}
+		responses = append(responses, imported)
+	}
+	return responses, nil
+}
+
+// LoadRawTraffic 读取原始流量文件: 只有响应时目标记为 http://name/,
+// 前面带着请求(Burp 的 Copy to file 等)时请求行是绝对地址就直接用,否则取 Host 头,没有 Host 头才用 name
+func LoadRawTraffic(data []byte, name string) (ImportedResponse, error) {
+	if IsRawResponse(data) {
+		resp, err := ParseRawResponse(data, name)
+		if err != nil {
+			return ImportedResponse{}, err
+		}
+		resp.URL = "http://" + name + "/"
+		return ImportedResponse{Method: http.MethodGet, Data: resp}, nil
+	}
+	reader := bufio.NewReader(bytes.NewReader(data))
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return ImportedResponse{}, fmt.Errorf("既不是原始响应也不是请求+响应: %w", err)
+	}
+	// 请求体之后才是响应
+	body, _ := io.ReadAll(req.Body)
+	rest, _ := io.ReadAll(reader)
+	u := *req.URL
+	if !u.IsAbs() {
+		u.Scheme, u.Host = "http", req.Host
+		if u.Host == "" {
+			u.Host = name
+		}
+	}
+	resp, err := ParseRawResponse(bytes.TrimLeft(rest, "\r\n"), u.Host)
+	if err != nil {
+		return ImportedResponse{}, err
+	}
+	resp.URL = u.String()
+	return ImportedResponse{Method: req.Method, Body: body, Data: resp}, nil
+}
+
+// rawRequestBody 原始请求中空行之后的请求体
+func rawRequestBody(raw []byte) []byte {
+	if i := bytes.Index(raw, []byte("\r\n\r\n")); i >= 0 {
+		return raw[i+4:]
+	}
+	if i := bytes.Index(raw, []byte("\n\n")); i >= 0 {
+		return raw[i+2:]
+	}
+	return nil
+}
+
+func newImported(method string, u *url.URL, status int, header http.Header, body []byte) ImportedResponse {
+	data := NewResponseData(u.Host, header, body)
+	data.Status = status
+	data.URL = u.String()
+	return ImportedResponse{Method: methodOrGet(method), Data: data}
+}
+
+func methodOrGet(method string) string {
+	if method == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(method)
+}
@@ -0,0 +1,129 @@
+package fingerprints
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+/*
+JS 和 source map 分析
+单页应用的 HTML 里几乎没有可匹配的内容,特征都在打包后的 JS 里。
+引用的脚本原样拼进 js 字段;脚本带 source map 时,再把其中的源文件路径(node_modules/框架名/...)
+和源码里的版权/版本注释("/*!" 开头或带 @license 的注释)提取出来追加进去
+*/
+
+var (
+	scriptSrcRegx = regexp.MustCompile(`(?i)<script[^>]*?\ssrc\s*=\s*["']?([^"'\s>]+)`)
+	sourceMapRegx = regexp.MustCompile(`[#@]\s*sourceMappingURL\s*=\s*(\S+)`)
+	// 打包工具会保留 "/*!" 开头和带 @license 的注释,一般是 "库名 v版本号"
+	bannerRegx = regexp.MustCompile(`/\*[^*]*\*+(?:[^/*][^*]*\*+)*/`)
+)
+
+// maxSignatureLen 每条注释最多保留的长度,整段许可证文本没有用
+const maxSignatureLen = 200
+
+// ScriptSources 页面中 <script src> 引用的地址,按出现顺序去重
+func ScriptSources(body string) []string {
+	var sources []string
+	seen := make(map[string]bool)
+	for _, m := range scriptSrcRegx.FindAllStringSubmatch(body, -1) {
+		if src := strings.TrimSpace(m[1]); src != "" && !seen[src] {
+			seen[src] = true
+			sources = append(sources, src)
+		}
+	}
+	return sources
+}
+
+// SourceMapURL 脚本的 source map 地址,取最后一个 sourceMappingURL 注释,没有时看 SourceMap / X-SourceMap 响应头
+func SourceMapURL(js, headers string) string {
+	if ms := sourceMapRegx.FindAllStringSubmatch(js, -1); len(ms) > 0 {
+		return ms[len(ms)-1][1]
+	}
+	for _, line := range strings.Split(headers, "\r\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if ok && (strings.EqualFold(key, "SourceMap") || strings.EqualFold(key, "X-SourceMap")) {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// DecodeDataURL 解码内联在脚本里的 data:application/json;base64,... 形式的 source map
+func DecodeDataURL(ref string) ([]byte, error) {
+	meta, data, ok := strings.Cut(strings.TrimPrefix(ref, "data:"), ",")
+	if !ok {
+		return nil, fmt.Errorf("data URL 格式错误")
+	}
+	if strings.HasSuffix(meta, ";base64") {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	text, err := url.PathUnescape(data)
+	return []byte(text), err
+}
+
+// SourceMap source map 中用于识别的部分
+type SourceMap struct {
+	Sources        []string `json:"sources"`
+	SourcesContent []string `json:"sourcesContent"`
+}
+
+// ParseSourceMap 解析 source map
+func ParseSourceMap(raw []byte) (*SourceMap, error) {
+	var sm SourceMap
+	if err := json.Unmarshal(raw, &sm); err != nil {
+		return nil, fmt.Errorf("解析 source map 失败: %w", err)
+	}
+	return &sm, nil
+}
+
+// Summary 源文件路径和源码中的版本注释,每项一行
+func (sm *SourceMap) Summary() string {
+	var sb strings.Builder
+	for _, source := range sm.Sources {
+		sb.WriteString(source)
+		sb.WriteByte('\n')
+	}
+	for _, content := range sm.SourcesContent {
+		for _, signature := range JSSignatures(content) {
+			sb.WriteString(signature)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// JSSignatures 提取脚本中的版权/版本注释,只保留第一行有内容的文字
+func JSSignatures(js string) []string {
+	var signatures []string
+	for _, comment := range bannerRegx.FindAllString(js, -1) {
+		if !strings.HasPrefix(comment, "/*!") && !strings.Contains(comment, "@license") {
+			continue
+		}
+		for _, line := range strings.Split(strings.Trim(comment, "/*! \r\n"), "\n") {
+			if line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "*")); line != "" {
+				if len(line) > maxSignatureLen {
+					line = line[:maxSignatureLen]
+				}
+				signatures = append(signatures, line)
+				break
+			}
+		}
+	}
+	return signatures
+}
+
+// UsesField 表达式中是否用到了某个字段
+func UsesField(node Node, field string) bool {
+	switch n := node.(type) {
+	case *ConditionNode:
+		return n.Field == field
+	case *BinaryOpNode:
+		return UsesField(n.Left, field) || UsesField(n.Right, field)
+	}
+	return false
+}
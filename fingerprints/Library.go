@@ -0,0 +1,91 @@
+package fingerprints
+
+import (
+	"PrintRaptor/source"
+)
+
+/*
+内置指纹库与用户指纹的叠加
+用户文件中出现的规则名会整体覆盖内置库中的同名规则(同名的多个路径变体一起替换),
+写上 disable: true 则直接禁用该名字的内置规则。
+过滤条件在叠加之后再应用: 用户覆盖的规则即使被过滤掉,内置库中的同名规则也不会回来
+*/
+
+// LoadBuiltinRules 加载编译进二进制的内置指纹库(web指纹 + 非HTTP服务的banner指纹)
+func LoadBuiltinRules() ([]CompiledRule, error) {
+	return loadBuiltinRules(currentFilter())
+}
+
+func loadBuiltinRules(filter *RuleFilter) ([]CompiledRule, error) {
+	rules, err := loadRulesFromBytes(source.FingerName, source.Finger, filter)
+	if err != nil {
+		return nil, err
+	}
+	service, err := loadRulesFromBytes(source.ServiceName, source.Service, filter)
+	if err != nil {
+		return nil, err
+	}
+	return append(rules, service...), nil
+}
+
+// LoadLibrary 加载内置指纹库(builtin 为真时)和 paths 中的用户指纹并叠加,最后应用过滤条件
+func LoadLibrary(builtin bool, paths []string, disabled ...string) ([]CompiledRule, error) {
+	var base, overlay []CompiledRule
+	var err error
+	if builtin {
+		if base, err = loadBuiltinRules(nil); err != nil {
+			return nil, err
+		}
+	}
+	if len(paths) > 0 {
+		if overlay, err = loadRulesFromPaths(paths, nil); err != nil {
+			return nil, err
+		}
+	}
+	return FilterRules(MergeRules(base, overlay, disabled...), currentFilter()), nil
+}
+
+// FilterRules 保留满足过滤条件的规则,filter 为 nil 时原样返回
+func FilterRules(rules []CompiledRule, filter *RuleFilter) []CompiledRule {
+	if filter == nil {
+		return rules
+	}
+	kept := make([]CompiledRule, 0, len(rules))
+	for _, rule := range rules {
+		if filter.Match(&rule.RuleConfig) {
+			kept = append(kept, rule)
+		}
+	}
+	return kept
+}
+
+// MergeRules 把 overlay 叠加到 base 上,disabled 为额外按名字禁用的规则
+// 返回的规则中不再包含 disable 条目
+func MergeRules(base, overlay []CompiledRule, disabled ...string) []CompiledRule {
+	overridden := make(map[string]bool)
+	off := make(map[string]bool)
+	for _, name := range disabled {
+		off[name] = true
+	}
+	for _, rule := range overlay {
+		if rule.Disable {
+			off[rule.Name] = true
+		} else {
+			overridden[rule.Name] = true
+		}
+	}
+	merged := make([]CompiledRule, 0, len(base)+len(overlay))
+	for _, rule := range base {
+		if rule.Disable || overridden[rule.Name] || off[rule.Name] {
+			continue
+		}
+		merged = append(merged, rule)
+	}
+	for _, rule := range overlay {
+		if rule.Disable || off[rule.Name] {
+			continue
+		}
+		merged = append(merged, rule)
+	}
+	return merged
+}
@@ -0,0 +1,523 @@
+package fingerprints
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+指纹文件校验(lint)
+LoadRulesFromFile 遇到解析失败的规则只会打印警告然后跳过,
+这里把整份文件按 yaml.Node 走一遍,尽可能把所有问题连同行列号一起报出来
+*/
+
+// LintLevel 问题级别
+type LintLevel string
+
+const (
+	LintError   LintLevel = "error"
+	LintWarning LintLevel = "warning"
+)
+
+// LintIssue 描述指纹文件中的一处问题
+type LintIssue struct {
+	File    string
+	Line    int
+	Column  int
+	Level   LintLevel
+	Rule    string // 所属规则名,可能为空
+	Message string
+}
+
+func (i LintIssue) String() string {
+	if i.Rule != "" {
+		return fmt.Sprintf("%s:%d:%d: %s: [%s] %s", i.File, i.Line, i.Column, i.Level, i.Rule, i.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", i.File, i.Line, i.Column, i.Level, i.Message)
+}
+
+// fieldKind 规则字段期望的 yaml 类型
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindInt
+	kindBool
+	kindMap
+	kindList
+	kindMapList
+	kindIntList
+)
+
+// ruleFields 与 RuleConfig 的 yaml tag 保持一致,新增字段时记得同步
+var ruleFields = map[string]fieldKind{
+	"name":       kindString,
+	"path":       kindString,
+	"expression": kindString,
+	"rank":       kindInt,
+	"tag":        kindString,
+	"isPost":     kindBool,
+	"tests":      kindMap,
+	"disable":    kindBool,
+	"conflicts":  kindList,
+	"requires":   kindList,
+	"implies":    kindList,
+	"steps":      kindMapList,
+	// 请求参数,见 Request.go
+	"method":      kindString,
+	"headers":     kindMap,
+	"body":        kindString,
+	"bodyBase64":  kindString,
+	"contentType": kindString,
+	"raw":         kindString,
+	// 非HTTP服务,见 Service.go
+	"protocol":   kindString,
+	"payload":    kindString,
+	"payloadHex": kindString,
+	"ports":      kindIntList,
+	// WAF 拦截页,见 Waf.go
+	"block": kindBool,
+}
+
+// sampleFields 规则测试样本的字段,见 RuleTest.go
+var sampleFields = map[string]fieldKind{
+	"file":   kindString,
+	"header": kindString,
+	"body":   kindString,
+	"hash":   kindString,
+	"js":     kindString,
+	"banner": kindString,
+	"status": kindInt,
+}
+
+// stepFields 多步请求中每一步的字段
+var stepFields = map[string]fieldKind{
+	"path":        kindString,
+	"isPost":      kindBool,
+	"expression":  kindString,
+	"extract":     kindMap,
+	"method":      kindString,
+	"headers":     kindMap,
+	"body":        kindString,
+	"bodyBase64":  kindString,
+	"contentType": kindString,
+}
+
+var yamlLineRegx = regexp.MustCompile(`line (\d+)`)
+
+// LintFile 读取并校验指纹文件,返回的 error 仅表示文件读取失败
+func LintFile(path string) ([]LintIssue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rule file %s: %w", path, err)
+	}
+	return LintData(path, data), nil
+}
+
+// LintFiles 校验多个文件、目录或通配符,除了逐个文件检查外还会报告跨文件重名的规则
+func LintFiles(paths []string) ([]LintIssue, int, error) {
+	files, err := ExpandRulePaths(paths)
+	if err != nil {
+		return nil, 0, err
+	}
+	var issues []LintIssue
+	seen := make(map[string]seenRule)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read rule file %s: %w", file, err)
+		}
+		issues = append(issues, lintData(file, data, seen)...)
+	}
+	return issues, len(files), nil
+}
+
+// LintData 校验一份指纹文件的内容,file 只用于输出定位
+func LintData(file string, data []byte) []LintIssue {
+	return lintData(file, data, make(map[string]seenRule))
+}
+
+// seenRule 记录已出现的规则名,用于重名检查
+type seenRule struct {
+	file string
+	node *yaml.Node
+	path string
+}
+
+func lintData(file string, data []byte, seen map[string]seenRule) []LintIssue {
+	var issues []LintIssue
+	report := func(node *yaml.Node, level LintLevel, rule, format string, args ...interface{}) {
+		issue := LintIssue{File: file, Level: level, Rule: rule, Message: fmt.Sprintf(format, args...)}
+		if node != nil {
+			issue.Line, issue.Column = node.Line, node.Column
+		}
+		issues = append(issues, issue)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		issue := LintIssue{File: file, Level: LintError, Message: err.Error()}
+		if m := yamlLineRegx.FindStringSubmatch(err.Error()); m != nil {
+			issue.Line, _ = strconv.Atoi(m[1])
+			issue.Column = 1
+		}
+		return append(issues, issue)
+	}
+	if len(doc.Content) == 0 {
+		// 空文件
+		return issues
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.SequenceNode {
+		report(root, LintError, "", "指纹文件顶层必须是规则列表")
+		return issues
+	}
+
+	for _, item := range root.Content {
+		if item.Kind != yaml.MappingNode {
+			report(item, LintError, "", "规则必须是键值映射")
+			continue
+		}
+		var nameNode, pathNode, exprNode, stepsNode, rawNode *yaml.Node
+		disabled, block := false, false
+		keys := make(map[string]*yaml.Node)
+		// 先取名字,后面的问题都挂在规则名下
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			if item.Content[i].Value == "name" {
+				nameNode = item.Content[i+1]
+				break
+			}
+		}
+		name := ""
+		if nameNode != nil {
+			name = nameNode.Value
+		}
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			key, val := item.Content[i], item.Content[i+1]
+			if prev, ok := keys[key.Value]; ok {
+				report(key, LintError, name, "字段 '%s' 重复定义(首次出现于第 %d 行)", key.Value, prev.Line)
+				continue
+			}
+			keys[key.Value] = key
+			kind, ok := ruleFields[key.Value]
+			if !ok {
+				if suggestion := suggestField(key.Value); suggestion != "" {
+					report(key, LintError, name, "未知字段 '%s',是否想写 '%s'?", key.Value, suggestion)
+				} else {
+					report(key, LintError, name, "未知字段 '%s'", key.Value)
+				}
+				continue
+			}
+			if !checkKind(val, kind) {
+				report(val, LintError, name, "字段 '%s' 类型错误,期望 %s", key.Value, kindName(kind))
+				continue
+			}
+			switch key.Value {
+			case "path":
+				pathNode = val
+			case "expression":
+				exprNode = val
+			case "tests":
+				lintTests(val, name, report)
+			case "disable":
+				disabled = val.Value == "true"
+			case "steps":
+				stepsNode = val
+			case "raw":
+				rawNode = val
+			case "block":
+				block = val.Value == "true"
+			}
+		}
+
+		if nameNode == nil || strings.TrimSpace(name) == "" {
+			report(item, LintError, "", "规则缺少 name")
+		}
+		if !disabled {
+			issues = append(issues, lintRequest(file, item, name, "")...)
+			var cfg RuleConfig
+			if item.Decode(&cfg) == nil {
+				if err := validateProtocol(&cfg); err != nil {
+					report(item, LintError, name, "%v", err)
+				}
+			}
+		}
+		if disabled {
+			// 禁用条目只需要名字
+			if exprNode != nil {
+				report(exprNode, LintWarning, name, "disable 条目的 expression 不会生效")
+			}
+			continue
+		}
+		if rawNode != nil {
+			if stepsNode != nil {
+				report(rawNode, LintError, name, "raw 和 steps 不能同时使用")
+			} else if !strings.Contains(rawNode.Value, " ") {
+				report(rawNode, LintError, name, "raw 缺少请求行")
+			}
+			for _, ignored := range []string{"path", "isPost", "method", "headers", "body", "bodyBase64", "contentType"} {
+				if key, ok := keys[ignored]; ok {
+					report(key, LintWarning, name, "写了 raw 时 '%s' 不会生效", ignored)
+				}
+			}
+		}
+		if stepsNode != nil {
+			issues = append(issues, lintSteps(file, stepsNode, name)...)
+			if exprNode != nil {
+				report(exprNode, LintWarning, name, "有 steps 时顶层的 expression 不会生效,请写在每一步中")
+			}
+		} else if exprNode == nil || strings.TrimSpace(exprNode.Value) == "" {
+			report(item, LintError, name, "规则缺少 expression")
+		} else if _, err := parseExpression(exprNode.Value); err != nil {
+			line, column := exprPosition(exprNode, err)
+			issues = append(issues, LintIssue{File: file, Line: line, Column: column, Level: LintError, Rule: name,
+				Message: "表达式解析失败: " + firstLine(err.Error())})
+		}
+		path := ""
+		if pathNode != nil {
+			path = pathNode.Value
+			if path != "" && !strings.HasPrefix(path, "/") {
+				report(pathNode, LintWarning, name, "path 应当以 / 开头")
+			}
+		}
+
+		if name == "" || nameNode == nil {
+			continue
+		}
+		// WAF 规则的识别条目和拦截页条目同名同路径,分开计算
+		key := name
+		if block {
+			key += "\x00block"
+		}
+		if prev, ok := seen[key]; ok {
+			if prev.file != file {
+				report(nameNode, LintWarning, name, "规则名与 %s:%d 中的规则重复", prev.file, prev.node.Line)
+			} else if prev.path == path {
+				report(nameNode, LintError, name, "规则重复: 与第 %d 行的规则名称和路径都相同", prev.node.Line)
+			} else {
+				report(nameNode, LintWarning, name, "规则名重复: 第 %d 行已定义同名规则(路径 '%s')", prev.node.Line, prev.path)
+			}
+			continue
+		}
+		seen[key] = seenRule{file: file, node: nameNode, path: path}
+	}
+	return issues
+}
+
+// HasLintErrors 判断是否存在 error 级别的问题,strict 为真时 warning 也算
+func HasLintErrors(issues []LintIssue, strict bool) bool {
+	for _, issue := range issues {
+		if issue.Level == LintError || strict {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestField 大小写写错的字段给出提示,比如 ispost / IsPost
+func suggestField(key string) string {
+	for field := range ruleFields {
+		if strings.EqualFold(field, key) {
+			return field
+		}
+	}
+	return ""
+}
+
+// lintTests 检查 tests 下的 match/noMatch 样本结构
+func lintTests(node *yaml.Node, rule string, report func(*yaml.Node, LintLevel, string, string, ...interface{})) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		if key.Value != "match" && key.Value != "noMatch" {
+			report(key, LintError, rule, "tests 下的未知字段 '%s',只支持 match / noMatch", key.Value)
+			continue
+		}
+		if val.Kind != yaml.SequenceNode {
+			report(val, LintError, rule, "tests.%s 必须是样本列表", key.Value)
+			continue
+		}
+		for _, sample := range val.Content {
+			if sample.Kind != yaml.MappingNode {
+				report(sample, LintError, rule, "样本必须是键值映射")
+				continue
+			}
+			for j := 0; j+1 < len(sample.Content); j += 2 {
+				field := sample.Content[j]
+				kind, ok := sampleFields[field.Value]
+				if !ok {
+					report(field, LintError, rule, "样本中的未知字段 '%s'", field.Value)
+				} else if !checkKind(sample.Content[j+1], kind) {
+					report(sample.Content[j+1], LintError, rule, "样本字段 '%s' 类型错误,期望 %s", field.Value, kindName(kind))
+				}
+			}
+		}
+	}
+}
+
+// lintSteps 检查多步请求,每一步的表达式和提取正则都要能编译,最后一步必须有 expression
+func lintSteps(file string, node *yaml.Node, rule string) []LintIssue {
+	var issues []LintIssue
+	report := func(n *yaml.Node, format string, args ...interface{}) {
+		issues = append(issues, LintIssue{File: file, Line: n.Line, Column: n.Column, Level: LintError, Rule: rule,
+			Message: fmt.Sprintf(format, args...)})
+	}
+	if len(node.Content) == 0 {
+		report(node, "steps 不能为空")
+		return issues
+	}
+	for i, step := range node.Content {
+		var exprNode *yaml.Node
+		for j := 0; j+1 < len(step.Content); j += 2 {
+			key, val := step.Content[j], step.Content[j+1]
+			kind, ok := stepFields[key.Value]
+			if !ok {
+				report(key, "第 %d 步的未知字段 '%s'", i+1, key.Value)
+				continue
+			}
+			if !checkKind(val, kind) {
+				report(val, "第 %d 步的字段 '%s' 类型错误,期望 %s", i+1, key.Value, kindName(kind))
+				continue
+			}
+			switch key.Value {
+			case "expression":
+				exprNode = val
+			case "extract":
+				for k := 0; k+1 < len(val.Content); k += 2 {
+					if _, err := compileExtractor(val.Content[k+1].Value); err != nil {
+						report(val.Content[k+1], "第 %d 步变量 '%s' 的正则无效: %v", i+1, val.Content[k].Value, err)
+					}
+				}
+			}
+		}
+		issues = append(issues, lintRequest(file, step, rule, fmt.Sprintf("第 %d 步", i+1))...)
+		if exprNode != nil && strings.TrimSpace(exprNode.Value) != "" {
+			if _, err := parseExpression(exprNode.Value); err != nil {
+				line, column := exprPosition(exprNode, err)
+				issues = append(issues, LintIssue{File: file, Line: line, Column: column, Level: LintError, Rule: rule,
+					Message: fmt.Sprintf("第 %d 步表达式解析失败: %s", i+1, firstLine(err.Error()))})
+			}
+		} else if i == len(node.Content)-1 {
+			report(step, "最后一步必须填写 expression")
+		}
+	}
+	return issues
+}
+
+// lintRequest 用 RequestOptions.Validate 检查请求方法和请求体,prefix 用于区分多步请求中的步骤
+func lintRequest(file string, node *yaml.Node, rule, prefix string) []LintIssue {
+	var opts RequestOptions
+	if err := node.Decode(&opts); err != nil {
+		// 类型错误已经在字段检查中报告过
+		return nil
+	}
+	if err := opts.Validate(); err != nil {
+		return []LintIssue{{File: file, Line: node.Line, Column: node.Column, Level: LintError, Rule: rule,
+			Message: prefix + err.Error()}}
+	}
+	return nil
+}
+
+func checkKind(node *yaml.Node, kind fieldKind) bool {
+	if kind == kindMap {
+		return node.Kind == yaml.MappingNode
+	}
+	if kind == kindMapList {
+		if node.Kind != yaml.SequenceNode {
+			return false
+		}
+		for _, item := range node.Content {
+			if item.Kind != yaml.MappingNode {
+				return false
+			}
+		}
+		return true
+	}
+	if kind == kindIntList {
+		if node.Kind != yaml.SequenceNode {
+			return false
+		}
+		for _, item := range node.Content {
+			if !checkKind(item, kindInt) {
+				return false
+			}
+		}
+		return true
+	}
+	if kind == kindList {
+		if node.Kind != yaml.SequenceNode {
+			return false
+		}
+		for _, item := range node.Content {
+			if !checkKind(item, kindString) {
+				return false
+			}
+		}
+		return true
+	}
+	if node.Kind != yaml.ScalarNode {
+		return false
+	}
+	switch kind {
+	case kindInt:
+		return node.Tag == "!!int"
+	case kindBool:
+		return node.Tag == "!!bool"
+	default:
+		// 数字之类的标量也能按字符串解出来,这里只拒绝 null
+		return node.Tag != "!!null"
+	}
+}
+
+func kindName(kind fieldKind) string {
+	switch kind {
+	case kindInt:
+		return "整数"
+	case kindBool:
+		return "布尔值"
+	case kindMap:
+		return "键值映射"
+	case kindList:
+		return "字符串列表"
+	case kindMapList:
+		return "键值映射列表"
+	case kindIntList:
+		return "整数列表"
+	default:
+		return "字符串"
+	}
+}
+
+// exprPosition 把表达式内的偏移换算成文件中的行列
+// 只有单行的标量能精确换算,块标量或带转义的字符串退化为标量起始位置。
+// ParseError 的偏移按字节计,yaml 的列号按字符计,中文等多字节字符要换算
+func exprPosition(node *yaml.Node, err error) (int, int) {
+	pe, ok := err.(*ParseError)
+	if !ok {
+		return node.Line, node.Column
+	}
+	pos := pe.Pos
+	if pos > len(node.Value) {
+		pos = len(node.Value)
+	}
+	offset := utf8.RuneCountInString(node.Value[:pos])
+	switch node.Style {
+	case 0:
+		return node.Line, node.Column + offset
+	case yaml.SingleQuotedStyle, yaml.DoubleQuotedStyle:
+		return node.Line, node.Column + 1 + offset
+	default:
+		return node.Line, node.Column
+	}
+}
+
+func firstLine(s string) string {
+	if idx := strings.IndexByte(s, '\n'); idx >= 0 {
+		return s[:idx]
+	}
+	return s
+}
@@ -1,14 +1,26 @@
 package fingerprints
 
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
 /*
 初始化加载指纹信息,并且进行分类
 */
-func LoadRules(path string) (CommonRules []CompiledRule, SpecialRules []CompiledRule, err error) {
-	totalRules, err := LoadRulesFromFile(path)
+func LoadRules(paths ...string) (CommonRules []CompiledRule, SpecialRules []CompiledRule, err error) {
+	totalRules, err := LoadRulesFromPaths(paths)
 	if err != nil {
 		return nil, nil, err
 	}
-	for _, rule := range totalRules {
+	// 去掉 disable 条目
+	for _, rule := range MergeRules(nil, totalRules) {
 		if rule.Path == "" || rule.Path == "/" {
 			CommonRules = append(CommonRules, rule)
 		} else {
@@ -17,3 +29,137 @@ func LoadRules(path string) (CommonRules []CompiledRule, SpecialRules []Compiled
 	}
 	return CommonRules, SpecialRules, nil
 }
+
+// LoadRulesFromPaths 从多个文件、目录或通配符加载规则
+// 目录会递归查找 .yaml/.yml 文件,跨文件的同名规则会打印警告
+func LoadRulesFromPaths(paths []string) ([]CompiledRule, error) {
+	return loadRulesFromPaths(paths, currentFilter())
+}
+
+func loadRulesFromPaths(paths []string, filter *RuleFilter) ([]CompiledRule, error) {
+	files, err := ExpandRulePaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	var totalRules []CompiledRule
+	for _, file := range files {
+		rules, err := loadRulesFromFile(file, filter)
+		if err != nil {
+			return nil, err
+		}
+		totalRules = append(totalRules, rules...)
+	}
+	for _, dup := range FindDuplicateRules(totalRules) {
+		fmt.Printf("⚠️ Warning: %s\n", dup.String())
+	}
+	return totalRules, nil
+}
+
+// ExpandRulePaths 把文件、目录、通配符展开成去重后的指纹文件列表,保持输入顺序
+func ExpandRulePaths(paths []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(file string) {
+		clean := filepath.Clean(file)
+		if !seen[clean] {
+			seen[clean] = true
+			files = append(files, clean)
+		}
+	}
+	for _, path := range paths {
+		matches := []string{path}
+		if strings.ContainsAny(path, "*?[") {
+			var err error
+			matches, err = filepath.Glob(path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rule path pattern %s: %w", path, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("rule path pattern %s matched no files", path)
+			}
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, fmt.Errorf("failed to stat rule path %s: %w", match, err)
+			}
+			if !info.IsDir() {
+				add(match)
+				continue
+			}
+			var found []string
+			err = filepath.WalkDir(match, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() && isRuleFile(p) {
+					found = append(found, p)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to walk rule directory %s: %w", match, err)
+			}
+			sort.Strings(found)
+			for _, file := range found {
+				add(file)
+			}
+		}
+	}
+	return files, nil
+}
+
+func isRuleFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// DuplicateRule 在多个文件中定义的同名规则
+type DuplicateRule struct {
+	Name  string
+	Rules []CompiledRule
+}
+
+func (d DuplicateRule) String() string {
+	locations := make([]string, 0, len(d.Rules))
+	for _, rule := range d.Rules {
+		locations = append(locations, fmt.Sprintf("%s:%d", rule.Source, rule.Line))
+	}
+	return fmt.Sprintf("规则 '%s' 在多个文件中重复定义: %s", d.Name, strings.Join(locations, ", "))
+}
+
+// FindDuplicateRules 找出跨文件重名的规则,同一文件内的同名规则(不同路径的变体)不算
+func FindDuplicateRules(rules []CompiledRule) []DuplicateRule {
+	byName := make(map[string][]CompiledRule)
+	var order []string
+	for _, rule := range rules {
+		if _, ok := byName[rule.Name]; !ok {
+			order = append(order, rule.Name)
+		}
+		byName[rule.Name] = append(byName[rule.Name], rule)
+	}
+	var dups []DuplicateRule
+	for _, name := range order {
+		group := byName[name]
+		for _, rule := range group[1:] {
+			if rule.Source != group[0].Source {
+				dups = append(dups, DuplicateRule{Name: name, Rules: group})
+				break
+			}
+		}
+	}
+	return dups
+}
+
+// RuleSetID 规则集的摘要,规则的名字、请求参数或表达式有任何变化都会得到不同的值
+// 用于断点续扫时判断已完成的目标是否需要用新规则重扫
+func RuleSetID(rules []CompiledRule) string {
+	h := sha1.New()
+	for _, rule := range rules {
+		// RuleConfig.Steps 中只有字符串和 map,fmt 输出稳定;编译后的 Steps 带指针,不能用
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s\x00%s\x00%d\x00%s\x00%q\x00%v\x00%v\n",
+			rule.Name, rule.Path, rule.HTTPMethod(rule.IsPost), rule.RequestOptions.Key(), rule.Raw, rule.Expression, rule.Rank,
+			rule.Protocol, rule.Payload, rule.Ports, rule.RuleConfig.Steps)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
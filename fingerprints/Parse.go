@@ -1,9 +1,12 @@
 package fingerprints
 
 import (
+	"bytes"
+	"encoding/hex"
 	"fmt"
 	"gopkg.in/yaml.v3"
 	"os"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -20,25 +23,71 @@ type RuleConfig struct {
 	Rank       int    `yaml:"rank"`
 	Tag        string `yaml:"tag"`
 	IsPost     bool   `yaml:"isPost"`
+	// RequestOptions 本规则自己的请求方法、请求头和请求体,见 Request.go
+	RequestOptions `yaml:",inline"`
+	// Raw 原始请求,原样发送,{{Host}}/{{Hostname}} 会替换为目标地址;写了 raw 时忽略 path/method 等参数
+	Raw string `yaml:"raw"`
+	// Protocol tcp/udp 表示非HTTP服务的banner探测,见 Service.go;为空即HTTP
+	Protocol string `yaml:"protocol"`
+	// Payload 建连后发送的探测数据,为空则只读取服务端主动发送的banner
+	Payload string `yaml:"payload"`
+	// PayloadHex 十六进制写法的 payload,二进制协议用它,yaml 双引号里的 \xNN 会被当成 Unicode 字符编码成多个字节
+	PayloadHex string `yaml:"payloadHex"`
+	// Ports 只对这些端口的目标做banner探测,为空不限制
+	Ports []int `yaml:"ports"`
+	// Tests 规则自带的正反样本,用于离线回归,见 RuleTest.go
+	Tests *RuleTests `yaml:"tests"`
+	// Disable 叠加在内置指纹库上时,按名字禁用同名的内置规则
+	Disable bool `yaml:"disable"`
+	// Conflicts 不可能与本产品同时出现的产品名,同时命中时在结果中标记冲突
+	Conflicts []string `yaml:"conflicts"`
+	// Requires 前置产品,全部识别出来后才会评估本规则(特殊路径规则才会发包)
+	Requires []string `yaml:"requires"`
+	// Implies 本规则命中后可以推断出的上级产品,比如某个OA模块推断出OA产品本身
+	Implies []string `yaml:"implies"`
+	// Steps 多步请求,按顺序发送,见 Steps.go;有 steps 时 path/isPost/expression 由每一步自己指定
+	Steps []RequestStep `yaml:"steps"`
+	// Block WAF 规则专用,命中表示响应是拦截页而不是应用本身,见 Waf.go
+	Block bool `yaml:"block"`
+}
+
+// DefaultRank 规则没有写 rank 时使用的默认值,与内置指纹库保持一致
+const DefaultRank = 130
+
+// EffectiveRank 规则实际使用的 rank,没有写时取 DefaultRank
+func (r *RuleConfig) EffectiveRank() int {
+	if r.Rank <= 0 {
+		return DefaultRank
+	}
+	return r.Rank
 }
 
 // CompiledRule 存储了从 YAML 加载的配置以及被解析后的 AST
 type CompiledRule struct {
 	RuleConfig
-	AST Node
+	AST    Node
+	Steps  []CompiledStep // 多步请求,AST 为最后一步的表达式
+	Source string         // 规则来源文件
+	Line   int            // 规则在来源文件中的行号
 }
 
 // ResponseData 存储从HTTP响应中提取的关键信息
+// 会被响应缓存序列化保存,见 models/cache.go
 type ResponseData struct {
-	Headers string
-	Body    string
-	Hash    string // Icon Hash
+	Headers string `json:"headers"`
+	Body    string `json:"body"`
+	Hash    string `json:"hash"`             // Icon Hash
+	Banner  string `json:"banner,omitempty"` // 非HTTP服务读到的原始banner
+	JS      string `json:"js,omitempty"`     // 引用的脚本及其 source map 摘要,见 JS.go
+	Status  int    `json:"status"`           // HTTP状态码
 	//前三个用于给Banner使用
-	BodyLength int
-	Cert       string
-	Title      string
-	ICP        string
-	Host       string // 用于存储请求的主机名或IP地址
+	BodyLength int    `json:"bodyLength"`
+	Cert       string `json:"cert,omitempty"`
+	Title      string `json:"title"`
+	ICP        string `json:"icp,omitempty"`
+	Host       string `json:"host"`                // 用于存储请求的主机名或IP地址
+	URL        string `json:"url"`                 // 实际请求的完整地址
+	Truncated  bool   `json:"truncated,omitempty"` // 响应体超过大小上限或读取超时,只保留了前面一部分
 	//FoundDomain string
 	//FoundIP     string
 }
@@ -52,18 +101,79 @@ type MatchedResult struct {
 
 // LoadRulesFromFile 从指定的 YAML 文件路径加载并编译所有规则
 func LoadRulesFromFile(filepath string) ([]CompiledRule, error) {
+	return loadRulesFromFile(filepath, currentFilter())
+}
+
+func loadRulesFromFile(filepath string, filter *RuleFilter) ([]CompiledRule, error) {
 	data, err := os.ReadFile(filepath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read rule file %s: %w", filepath, err)
 	}
+	return loadRulesFromBytes(filepath, data, filter)
+}
 
-	var configs []RuleConfig
-	if err := yaml.Unmarshal(data, &configs); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal yaml: %w", err)
+// LoadRulesFromBytes 编译一份指纹文件的内容,source 记录在每条规则上用于溯源
+func LoadRulesFromBytes(source string, data []byte) ([]CompiledRule, error) {
+	return loadRulesFromBytes(source, data, currentFilter())
+}
+
+// loadRulesFromBytes filter 为 nil 时不过滤
+func loadRulesFromBytes(source string, data []byte, filter *RuleFilter) ([]CompiledRule, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal yaml %s: %w", source, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("failed to unmarshal yaml %s: 顶层必须是规则列表", source)
 	}
 
 	var compiledRules []CompiledRule
-	for _, config := range configs {
+	for _, item := range root.Content {
+		// 逐条解码,单条规则字段类型不对时只跳过这一条
+		var config RuleConfig
+		if err := item.Decode(&config); err != nil {
+			fmt.Printf("⚠️ Warning: Skipping rule at %s:%d due to decoding error\n", source, item.Line)
+			fmt.Printf("  Error: %v\n", err)
+			continue
+		}
+		if config.Disable {
+			// 禁用条目只有名字有意义,不需要表达式,由 MergeRules 处理
+			compiledRules = append(compiledRules, CompiledRule{RuleConfig: config, Source: source, Line: item.Line})
+			continue
+		}
+		if filter != nil && !filter.Match(&config) {
+			continue
+		}
+		if err := validateProtocol(&config); err != nil {
+			fmt.Printf("⚠️ Warning: Skipping rule '%s' due to protocol error\n", config.Name)
+			fmt.Printf("  Error: %v\n", err)
+			continue
+		}
+		if err := config.RequestOptions.Validate(); err != nil {
+			fmt.Printf("⚠️ Warning: Skipping rule '%s' due to request error\n", config.Name)
+			fmt.Printf("  Error: %v\n", err)
+			continue
+		}
+		if len(config.Steps) > 0 {
+			steps, err := compileSteps(config.Steps)
+			if err != nil {
+				fmt.Printf("⚠️ Warning: Skipping rule '%s' due to step error\n", config.Name)
+				fmt.Printf("  Error: %v\n", err)
+				continue
+			}
+			compiledRules = append(compiledRules, CompiledRule{
+				RuleConfig: config,
+				AST:        steps[len(steps)-1].AST,
+				Steps:      steps,
+				Source:     source,
+				Line:       item.Line,
+			})
+			continue
+		}
 		// 复用之前的表达式解析器
 		ast, err := parseExpression(config.Expression)
 		if err != nil {
@@ -76,6 +186,8 @@ func LoadRulesFromFile(filepath string) ([]CompiledRule, error) {
 		compiledRules = append(compiledRules, CompiledRule{
 			RuleConfig: config,
 			AST:        ast,
+			Source:     source,
+			Line:       item.Line,
 		})
 	}
 	return compiledRules, nil
@@ -107,6 +219,14 @@ type Token struct {
 	Pos   int // 添加位置信息
 }
 
+// ParseError 表达式解析错误,Pos 为出错位置在表达式中的偏移,供 lint 定位行列
+type ParseError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string { return e.Msg }
+
 type Lexer struct {
 	input string
 	pos   int
@@ -262,6 +382,22 @@ func (c *ConditionNode) Eval(data *ResponseData) bool {
 		targetValue = data.Headers
 	case "hash":
 		targetValue = data.Hash
+	case "banner":
+		targetValue = data.Banner
+	case "bannerHex":
+		// Value 在解析时已解码为原始字节,按前缀匹配
+		if c.Operator == TokenNotEquals {
+			return !strings.HasPrefix(data.Banner, c.Value)
+		}
+		return c.Operator == TokenEquals && strings.HasPrefix(data.Banner, c.Value)
+	case "js":
+		targetValue = data.JS
+	case "status":
+		// 状态码按整个值比较,不做包含匹配
+		if c.Operator == TokenNotEquals {
+			return strconv.Itoa(data.Status) != c.Value
+		}
+		return c.Operator == TokenEquals && strconv.Itoa(data.Status) == c.Value
 	default:
 		return false
 	}
@@ -317,8 +453,8 @@ func (p *Parser) expect(tt TokenType) (Token, error) {
 		p.advance()
 		return t, nil
 	}
-	return Token{}, fmt.Errorf("语法错误: 期望 %v, 但得到 %v (位置: %d)",
-		tt, p.current().Type, p.current().Pos)
+	return Token{}, &ParseError{Pos: p.current().Pos, Msg: fmt.Sprintf("语法错误: 期望 %v, 但得到 %v (位置: %d)",
+		tt, p.current().Type, p.current().Pos)}
 }
 func (p *Parser) Parse() (Node, error) {
 	n, err := p.parseExpression()
@@ -326,8 +462,8 @@ func (p *Parser) Parse() (Node, error) {
 		return nil, err
 	}
 	if p.current().Type != TokenEOF {
-		return nil, fmt.Errorf("语法错误: 表达式尾部有多余内容 '%s' (位置: %d)",
-			p.current().Value, p.current().Pos)
+		return nil, &ParseError{Pos: p.current().Pos, Msg: fmt.Sprintf("语法错误: 表达式尾部有多余内容 '%s' (位置: %d)",
+			p.current().Value, p.current().Pos)}
 	}
 	return n, nil
 }
@@ -382,10 +518,10 @@ func (p *Parser) parseCondition() (Node, error) {
 	if err != nil {
 		return nil, err
 	}
-	// 只保留原始字段：body, header, hash
-	validFields := map[string]bool{"body": true, "header": true, "hash": true}
+	// 原始字段：body, header, hash, status,非HTTP服务的 banner(bannerHex 按字节前缀匹配),以及引用脚本的 js
+	validFields := map[string]bool{"body": true, "header": true, "hash": true, "banner": true, "bannerHex": true, "status": true, "js": true}
 	if !validFields[ident.Value] {
-		return nil, fmt.Errorf("无效字段名: '%s' (位置: %d)", ident.Value, ident.Pos)
+		return nil, &ParseError{Pos: ident.Pos, Msg: fmt.Sprintf("无效字段名: '%s' (位置: %d)", ident.Value, ident.Pos)}
 	}
 
 	// 检查操作符类型（= 或 !=）
@@ -398,14 +534,21 @@ func (p *Parser) parseCondition() (Node, error) {
 		operator = TokenNotEquals
 		p.advance()
 	default:
-		return nil, fmt.Errorf("语法错误: 期望 = 或 !=, 但得到 %v (位置: %d)",
-			p.current().Type, p.current().Pos)
+		return nil, &ParseError{Pos: p.current().Pos, Msg: fmt.Sprintf("语法错误: 期望 = 或 !=, 但得到 %v (位置: %d)",
+			p.current().Type, p.current().Pos)}
 	}
 
 	str, err := p.expect(TokenString)
 	if err != nil {
 		return nil, err
 	}
+	if ident.Value == "bannerHex" {
+		raw, err := decodeHex(str.Value)
+		if err != nil {
+			return nil, &ParseError{Pos: str.Pos, Msg: fmt.Sprintf("bannerHex 不是合法的十六进制: %v (位置: %d)", err, str.Pos)}
+		}
+		str.Value = string(raw)
+	}
 
 	return &ConditionNode{
 		Field:    ident.Value,
@@ -421,7 +564,7 @@ func parseExpression(expression string) (Node, error) {
 	for {
 		tok := lexer.nextToken()
 		if tok.Type == TokenError {
-			return nil, fmt.Errorf("词法错误: %s", tok.Value)
+			return nil, &ParseError{Pos: tok.Pos, Msg: "词法错误: " + tok.Value}
 		}
 		tokens = append(tokens, tok)
 		if tok.Type == TokenEOF {
@@ -458,3 +601,8 @@ var compiledRule []CompiledRule
 func init() {
 	//初始化
 }
+
+// decodeHex 解码十六进制字符串,允许用空格分隔字节
+func decodeHex(s string) ([]byte, error) {
+	return hex.DecodeString(string(bytes.Join(bytes.Fields([]byte(s)), nil)))
+}
@@ -0,0 +1,112 @@
+package fingerprints
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+/*
+单条规则的请求参数
+原来只有 isPost 一个开关,POST 的请求体也只能用config里全局的那一份;
+现在每条规则(以及多步请求的每一步)都可以单独指定方法、请求头、请求体
+*/
+
+// SupportedMethods 规则可以使用的请求方法
+var SupportedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodOptions, http.MethodHead}
+
+// RequestOptions 规则自己的请求参数,未填写的项使用全局配置
+type RequestOptions struct {
+	Method      string            `yaml:"method"`      // 默认 GET,isPost: true 等价于 POST
+	Headers     map[string]string `yaml:"headers"`     // 覆盖config中的同名请求头
+	Body        string            `yaml:"body"`        // 原样发送的请求体
+	BodyBase64  string            `yaml:"bodyBase64"`  // base64 编码的请求体,适合二进制内容
+	ContentType string            `yaml:"contentType"` // 等同于 headers 中的 Content-Type
+}
+
+// Validate 检查请求方法和 base64 请求体
+func (o *RequestOptions) Validate() error {
+	if o.Method != "" {
+		method := strings.ToUpper(o.Method)
+		valid := false
+		for _, m := range SupportedMethods {
+			if m == method {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("不支持的请求方法 '%s',可选 %s", o.Method, strings.Join(SupportedMethods, "/"))
+		}
+	}
+	if o.Body != "" && o.BodyBase64 != "" {
+		return fmt.Errorf("body 和 bodyBase64 只能填写一个")
+	}
+	if o.BodyBase64 != "" {
+		if _, err := base64.StdEncoding.DecodeString(o.BodyBase64); err != nil {
+			return fmt.Errorf("bodyBase64 解码失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// HTTPMethod 实际使用的请求方法
+func (o *RequestOptions) HTTPMethod(isPost bool) string {
+	if o.Method != "" {
+		return strings.ToUpper(o.Method)
+	}
+	if isPost {
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
+
+// RequestBody 规则自己的请求体,没有填写时返回 ok=false,由调用方决定是否使用全局请求体
+func (o *RequestOptions) RequestBody() (body []byte, ok bool) {
+	if o.BodyBase64 != "" {
+		data, err := base64.StdEncoding.DecodeString(o.BodyBase64)
+		return data, err == nil
+	}
+	if o.Body != "" {
+		return []byte(o.Body), true
+	}
+	return nil, false
+}
+
+// Expand 替换请求头和请求体中的变量,多步请求使用
+func (o RequestOptions) Expand(vars map[string]string) RequestOptions {
+	if len(vars) == 0 {
+		return o
+	}
+	expanded := o
+	if len(o.Headers) > 0 {
+		expanded.Headers = make(map[string]string, len(o.Headers))
+		for k, v := range o.Headers {
+			expanded.Headers[k] = ExpandVars(v, vars)
+		}
+	}
+	expanded.Body = ExpandVars(o.Body, vars)
+	expanded.ContentType = ExpandVars(o.ContentType, vars)
+	return expanded
+}
+
+// Key 请求参数的唯一标识,参数相同的请求可以共用响应
+func (o *RequestOptions) Key() string {
+	if len(o.Headers) == 0 && o.Body == "" && o.BodyBase64 == "" && o.ContentType == "" {
+		return ""
+	}
+	keys := make([]string, 0, len(o.Headers))
+	for k := range o.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(strings.ToLower(k) + ":" + o.Headers[k] + "\n")
+	}
+	sb.WriteString("content-type:" + o.ContentType + "\n")
+	sb.WriteString(o.Body + o.BodyBase64)
+	return sb.String()
+}
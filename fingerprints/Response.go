@@ -0,0 +1,97 @@
+package fingerprints
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+/*
+ResponseData 的构造,在线请求(http.extract)和离线样本共用同一套提取逻辑,
+保证两边匹配出的结果一致
+*/
+
+// NewResponseData 根据响应头和响应体构造 ResponseData,icon hash 需要调用方单独填充
+func NewResponseData(host string, header http.Header, body []byte) *ResponseData {
+	responseData := &ResponseData{
+		Host:    host,
+		Headers: HeaderToString(header),
+		Body:    string(body),
+	}
+	if responseData.Body == "" {
+		return responseData
+	}
+	responseData.ICP = GetICP(responseData.Body)
+	responseData.BodyLength = len(body)
+	responseData.Title, _ = GetTitle(responseData.Body)
+	return responseData
+}
+
+// ParseRawResponse 解析一段原始HTTP响应(状态行+响应头+响应体)
+func ParseRawResponse(raw []byte, host string) (*ResponseData, error) {
+	// 手工保存的文件经常是 \n 换行,net/http 也能兼容
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(raw)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("解析原始响应失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil && len(body) == 0 {
+		return nil, fmt.Errorf("读取原始响应体失败: %w", err)
+	}
+	data := NewResponseData(host, resp.Header, body)
+	data.Status = resp.StatusCode
+	return data, nil
+}
+
+// IsRawResponse 粗略判断内容是否为带状态行的原始响应
+func IsRawResponse(raw []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(raw, "\r\n\t "), []byte("HTTP/"))
+}
+
+func HeaderToString(header http.Header) string {
+	var sb strings.Builder
+	for key, values := range header {
+		for _, value := range values {
+			sb.WriteString(key)
+			sb.WriteString(": ")
+			sb.WriteString(value)
+			sb.WriteString("\r\n")
+		}
+	}
+	return sb.String()
+}
+
+var titleRegx = regexp.MustCompile(`(?i)<title[^>]*>(.*?)</title>`)
+
+// GetTitle 提取 HTML 中的 <title> 内容
+func GetTitle(body string) (string, error) {
+	// 正则提取 <title> 标签内容（忽略大小写）
+	match := titleRegx.FindStringSubmatch(body)
+	if len(match) > 1 {
+		// 清理前后空白
+		return strings.TrimSpace(match[1]), nil
+	}
+	return "", nil // 没有 <title> 标签
+}
+
+var (
+	provinces = []string{
+		"京", "津", "冀", "晋", "蒙", "辽", "吉", "黑",
+		"沪", "苏", "浙", "皖", "闽", "赣", "鲁", "豫",
+		"湘", "粤", "桂", "琼", "川", "蜀", "贵", "黔",
+		"云", "滇", "渝", "藏", "陕", "秦", "甘", "陇",
+		"青", "宁", "新", "港", "澳", "台", "鄂",
+	}
+	provincesString = strings.Join(provinces, "|")
+	icpRegx         = regexp.MustCompile(`(?:` + provincesString + `)ICP备\s*\d+号(?:-\d+)?`)
+)
+
+// GetICP 提取备案号
+func GetICP(body string) string {
+	return icpRegx.FindString(body)
+}
@@ -0,0 +1,136 @@
+package fingerprints
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+/*
+指纹自带样本的离线回归
+每条规则可以在 tests 里写上应当命中(match)和不应命中(noMatch)的响应样本,
+改完指纹跑一遍就知道有没有把原来能识别的东西改坏
+*/
+
+// RuleTests 规则的正反样本
+type RuleTests struct {
+	Match   []RuleSample `yaml:"match"`
+	NoMatch []RuleSample `yaml:"noMatch"`
+}
+
+// RuleSample 一条样本响应
+// 可以直接内联 header/body/hash/js,也可以用 file 引用文件(相对于指纹文件所在目录),
+// 文件内容以 HTTP/ 开头时按原始响应解析,否则整个文件当作 body
+type RuleSample struct {
+	File   string `yaml:"file"`
+	Header string `yaml:"header"`
+	Body   string `yaml:"body"`
+	Hash   string `yaml:"hash"`
+	JS     string `yaml:"js"`
+	Banner string `yaml:"banner"` // 非HTTP服务的banner
+	Status int    `yaml:"status"` // HTTP状态码
+}
+
+// RuleTestFailure 一条不符合预期的样本
+type RuleTestFailure struct {
+	Rule   string
+	Source string // 规则所在文件:行号
+	Sample string // 样本标识,比如 match[0] 或 noMatch[1](file.txt)
+	Expect bool   // 期望是否命中
+	Err    error  // 样本本身加载失败
+}
+
+func (f RuleTestFailure) String() string {
+	if f.Err != nil {
+		return fmt.Sprintf("%s: [%s] %s: 样本加载失败: %v", f.Source, f.Rule, f.Sample, f.Err)
+	}
+	if f.Expect {
+		return fmt.Sprintf("%s: [%s] %s: 期望命中,实际未命中", f.Source, f.Rule, f.Sample)
+	}
+	return fmt.Sprintf("%s: [%s] %s: 期望不命中,实际命中", f.Source, f.Rule, f.Sample)
+}
+
+// RuleTestReport 一次回归的汇总
+type RuleTestReport struct {
+	Rules    int // 带样本的规则数
+	Samples  int // 样本总数
+	Failures []RuleTestFailure
+}
+
+// RunRuleTests 对每条规则的样本求值,样本中的相对路径相对于规则来源文件所在目录
+func RunRuleTests(rules []CompiledRule) RuleTestReport {
+	var report RuleTestReport
+	for _, rule := range rules {
+		// 禁用条目只有名字,没有可以求值的表达式
+		if rule.Tests == nil || rule.Disable || rule.AST == nil {
+			continue
+		}
+		report.Rules++
+		baseDir := filepath.Dir(rule.Source)
+		source := fmt.Sprintf("%s:%d", rule.Source, rule.Line)
+		check := func(kind string, samples []RuleSample, expect bool) {
+			for i, sample := range samples {
+				report.Samples++
+				id := fmt.Sprintf("%s[%d]", kind, i)
+				if sample.File != "" {
+					id += "(" + sample.File + ")"
+				}
+				data, err := sample.Load(baseDir)
+				if err != nil {
+					report.Failures = append(report.Failures, RuleTestFailure{Rule: rule.Name, Source: source, Sample: id, Expect: expect, Err: err})
+					continue
+				}
+				if rule.AST.Eval(data) != expect {
+					report.Failures = append(report.Failures, RuleTestFailure{Rule: rule.Name, Source: source, Sample: id, Expect: expect})
+				}
+			}
+		}
+		check("match", rule.Tests.Match, true)
+		check("noMatch", rule.Tests.NoMatch, false)
+	}
+	return report
+}
+
+// Load 把样本转成 ResponseData,内联字段会覆盖文件中解析出的同名内容
+func (s RuleSample) Load(baseDir string) (*ResponseData, error) {
+	data := &ResponseData{}
+	if s.File != "" {
+		path := s.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if IsRawResponse(raw) {
+			data, err = ParseRawResponse(raw, "")
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			data = NewResponseData("", nil, raw)
+		}
+	}
+	if s.Header != "" {
+		data.Headers = s.Header
+	}
+	if s.Body != "" {
+		data.Body = s.Body
+		data.BodyLength = len(s.Body)
+		data.Title, _ = GetTitle(s.Body)
+	}
+	if s.Hash != "" {
+		data.Hash = s.Hash
+	}
+	if s.JS != "" {
+		data.JS = s.JS
+	}
+	if s.Banner != "" {
+		data.Banner = s.Banner
+	}
+	if s.Status != 0 {
+		data.Status = s.Status
+	}
+	return data, nil
+}
@@ -0,0 +1,75 @@
+package fingerprints
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+非HTTP服务的banner探测
+SSH、FTP、Redis、MySQL、RDP 这类端口走 tcp/udp 直接建连,按需发送 payload 后读取 banner,
+规则里用 banner="..." 匹配,二进制协议用 payloadHex 和 bannerHex="..." 按字节书写,结果与HTTP规则走同一套输出
+*/
+
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+// IsServiceProtocol 目标或规则的协议是否为非HTTP服务
+func IsServiceProtocol(protocol string) bool {
+	return protocol == ProtocolTCP || protocol == ProtocolUDP
+}
+
+// IsServiceRule 规则是否为banner探测规则
+func (r *RuleConfig) IsServiceRule() bool {
+	return IsServiceProtocol(r.Protocol)
+}
+
+// MatchPort 规则是否适用于该端口
+func (r *RuleConfig) MatchPort(port int) bool {
+	if len(r.Ports) == 0 {
+		return true
+	}
+	for _, p := range r.Ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// validateProtocol 统一协议名的大小写,banner规则不能与HTTP相关的写法混用
+func validateProtocol(r *RuleConfig) error {
+	r.Protocol = strings.ToLower(r.Protocol)
+	if r.PayloadHex != "" {
+		if r.Payload != "" {
+			return fmt.Errorf("payload 和 payloadHex 只能写一个")
+		}
+		raw, err := decodeHex(r.PayloadHex)
+		if err != nil {
+			return fmt.Errorf("payloadHex 不是合法的十六进制: %v", err)
+		}
+		r.Payload = string(raw)
+	}
+	switch r.Protocol {
+	case "", "http":
+		r.Protocol = ""
+		if r.Payload != "" || len(r.Ports) > 0 {
+			return fmt.Errorf("payload/ports 只能用于 protocol: tcp/udp 的规则")
+		}
+		return nil
+	case ProtocolTCP, ProtocolUDP:
+		if r.Raw != "" || len(r.Steps) > 0 {
+			return fmt.Errorf("protocol: %s 的规则不能使用 raw/steps", r.Protocol)
+		}
+		for _, p := range r.Ports {
+			if p <= 0 || p > 65535 {
+				return fmt.Errorf("无效端口 %d", p)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("不支持的协议 '%s',可选 tcp/udp,为空表示HTTP", r.Protocol)
+	}
+}
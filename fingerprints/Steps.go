@@ -0,0 +1,115 @@
+package fingerprints
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*
+多步请求
+有些产品要先请求一个页面,从里面拿到 token 或者真实路径,再去请求第二个地址才能识别。
+每一步可以用 extract 从自己的响应里提取变量,后面步骤的 path 中用 {{变量名}} 引用;
+每一步的 expression 只对这一步自己的响应求值,所有写了 expression 的步骤都命中,规则才算命中
+*/
+
+// RequestStep 多步请求中的一步
+type RequestStep struct {
+	Path       string `yaml:"path"`
+	IsPost     bool   `yaml:"isPost"`
+	Expression string `yaml:"expression"` // 为空表示这一步只提取变量不做判断,最后一步必须填写
+	// RequestOptions 这一步的请求方法、请求头和请求体,请求头和请求体中同样可以引用变量
+	RequestOptions `yaml:",inline"`
+	// Extract 变量名 -> 正则,取第一个捕获组(没有捕获组取整个匹配);
+	// 默认在响应体中查找,正则以 header: 开头时在响应头中查找
+	Extract map[string]string `yaml:"extract"`
+}
+
+// CompiledStep 编译后的请求步骤
+type CompiledStep struct {
+	RequestStep
+	AST     Node // 没有 expression 时为 nil
+	extract map[string]extractor
+}
+
+type extractor struct {
+	header bool
+	re     *regexp.Regexp
+}
+
+func compileSteps(steps []RequestStep) ([]CompiledStep, error) {
+	compiled := make([]CompiledStep, 0, len(steps))
+	for i, step := range steps {
+		if err := step.RequestOptions.Validate(); err != nil {
+			return nil, fmt.Errorf("第 %d 步: %w", i+1, err)
+		}
+		cs := CompiledStep{RequestStep: step, extract: make(map[string]extractor)}
+		if strings.TrimSpace(step.Expression) != "" {
+			ast, err := parseExpression(step.Expression)
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 步表达式解析失败: %w", i+1, err)
+			}
+			cs.AST = ast
+		}
+		for name, pattern := range step.Extract {
+			ex, err := compileExtractor(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 步变量 %s 的正则无效: %w", i+1, name, err)
+			}
+			cs.extract[name] = ex
+		}
+		compiled = append(compiled, cs)
+	}
+	if compiled[len(compiled)-1].AST == nil {
+		return nil, fmt.Errorf("最后一步必须填写 expression")
+	}
+	return compiled, nil
+}
+
+func compileExtractor(pattern string) (extractor, error) {
+	ex := extractor{}
+	if strings.HasPrefix(pattern, "header:") {
+		ex.header = true
+		pattern = strings.TrimPrefix(pattern, "header:")
+	}
+	re, err := regexp.Compile(pattern)
+	ex.re = re
+	return ex, err
+}
+
+// ExtractVars 从响应中提取变量写入 vars,返回没能提取到的变量名
+func (s *CompiledStep) ExtractVars(data *ResponseData, vars map[string]string) []string {
+	var missing []string
+	for name, ex := range s.extract {
+		source := data.Body
+		if ex.header {
+			source = data.Headers
+		}
+		match := ex.re.FindStringSubmatch(source)
+		switch {
+		case match == nil:
+			missing = append(missing, name)
+		case len(match) > 1:
+			vars[name] = match[1]
+		default:
+			vars[name] = match[0]
+		}
+	}
+	return missing
+}
+
+var varRegx = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// ExpandVars 替换字符串中的 {{变量名}},未定义的变量保持原样
+func ExpandVars(s string, vars map[string]string) string {
+	if len(vars) == 0 || !strings.Contains(s, "{{") {
+		return s
+	}
+	return varRegx.ReplaceAllStringFunc(s, func(m string) string {
+		name := varRegx.FindStringSubmatch(m)[1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return m
+	})
+}
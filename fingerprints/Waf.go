@@ -0,0 +1,41 @@
+package fingerprints
+
+import (
+	"PrintRaptor/source"
+)
+
+/*
+WAF/CDN 识别
+规则写法与指纹规则相同(source/waf.yaml),对每个HTTP响应求值;
+写了 block: true 的规则命中说明拿到的是拦截页,调用方据此跳过产品匹配、退避或停止探测
+*/
+
+// WafHit 一次 WAF/CDN 识别结果
+type WafHit struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`  // waf 或 cdn,取规则的 tag
+	Block bool   `json:"block"` // 响应是拦截页
+}
+
+// LoadWafRules 加载内置的WAF/CDN规则,不受规则过滤条件影响
+func LoadWafRules() ([]CompiledRule, error) {
+	return loadRulesFromBytes(source.WafName, source.Waf, nil)
+}
+
+// DetectWaf 用WAF规则对响应求值,返回命中的WAF/CDN以及响应是否为拦截页
+func DetectWaf(rules []CompiledRule, data *ResponseData) (hits []WafHit, blocked bool) {
+	if data == nil {
+		return nil, false
+	}
+	for i := range rules {
+		rule := &rules[i]
+		if rule.AST == nil || !rule.AST.Eval(data) {
+			continue
+		}
+		hits = append(hits, WafHit{Name: rule.Name, Kind: rule.Tag, Block: rule.Block})
+		if rule.Block {
+			blocked = true
+		}
+	}
+	return hits, blocked
+}
@@ -0,0 +1,96 @@
+package fingerprints
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRuleFilter(t *testing.T) {
+	rules := []RuleConfig{
+		{Name: "seeyon-oa", Path: "/seeyon/index.jsp", Rank: 200, Tag: "OA系统"},
+		{Name: "weaver-oa", Path: "/", Rank: 130, Tag: "OA系统,国产"},
+		{Name: "nginx", Path: "", Rank: 130},
+		{Name: "weblogic-console", Path: "/console/login", Rank: 180, Tag: "中间件"},
+	}
+	cases := []struct {
+		filter RuleFilter
+		want   []bool
+	}{
+		{RuleFilter{IncludeTags: []string{"oa*"}}, []bool{true, true, false, false}},
+		{RuleFilter{ExcludeTags: []string{"国产"}}, []bool{true, false, true, true}},
+		{RuleFilter{MinRank: 150}, []bool{true, false, false, true}},
+		{RuleFilter{IncludeNames: []string{"re:^we"}}, []bool{false, true, false, true}},
+		{RuleFilter{ExcludeNames: []string{"*-oa"}}, []bool{false, false, true, true}},
+		{RuleFilter{IncludePaths: []string{"/"}}, []bool{false, true, true, false}},
+		{RuleFilter{ExcludePaths: []string{"/console*"}, MaxRank: 150}, []bool{false, true, true, false}},
+	}
+	for i, c := range cases {
+		for j := range rules {
+			if got := c.filter.Match(&rules[j]); got != c.want[j] {
+				t.Errorf("case %d rule %s: 期望 %v, 实际 %v", i, rules[j].Name, c.want[j], got)
+			}
+		}
+	}
+}
+
+func TestLoadWithRuleFilter(t *testing.T) {
+	if err := SetRuleFilter(&RuleFilter{IncludeNames: []string{"nginx"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetRuleFilter(nil)
+	data := "- name: nginx\n  expression: header=\"nginx\"\n- name: iis\n  expression: header=\"IIS\"\n"
+	rules, err := LoadRulesFromBytes("rules.yaml", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].Name != "nginx" {
+		t.Fatalf("过滤未生效: %v", rules)
+	}
+	if err := SetRuleFilter(&RuleFilter{IncludeNames: []string{"re:("}}); err == nil {
+		t.Errorf("非法正则应当报错")
+	}
+}
+
+func TestLoadLibraryFilterAfterMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user.yaml")
+	data := "- name: ssh\n  tag: legacy\n  expression: banner=\"SSH-1.\"\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetRuleFilter(&RuleFilter{ExcludeTags: []string{"legacy"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetRuleFilter(nil)
+	rules, err := LoadLibrary(true, []string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) == 0 {
+		t.Fatal("内置指纹库不应为空")
+	}
+	for _, rule := range rules {
+		if rule.Name == "ssh" {
+			t.Fatalf("用户覆盖的 ssh 规则被过滤后,内置的同名规则不应回来: %+v", rule.RuleConfig)
+		}
+	}
+}
+
+// TestRuleFilterDefaultRank 没写 rank 的规则按默认的 130 参与 MinRank / MaxRank 过滤
+func TestRuleFilterDefaultRank(t *testing.T) {
+	rule := &RuleConfig{Name: "nginx"}
+	cases := []struct {
+		filter RuleFilter
+		want   bool
+	}{
+		{RuleFilter{MinRank: 100}, true},
+		{RuleFilter{MinRank: 150}, false},
+		{RuleFilter{MaxRank: 150}, true},
+		{RuleFilter{MaxRank: 100}, false},
+	}
+	for _, c := range cases {
+		if got := c.filter.Match(rule); got != c.want {
+			t.Errorf("MinRank=%d MaxRank=%d: 期望 %v, 实际 %v", c.filter.MinRank, c.filter.MaxRank, c.want, got)
+		}
+	}
+}
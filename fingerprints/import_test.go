@@ -0,0 +1,108 @@
+package fingerprints
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTraffic(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadHAR(t *testing.T) {
+	icon := base64.StdEncoding.EncodeToString([]byte{0, 1, 2, 3})
+	path := writeTraffic(t, "site.har", `{"log":{"entries":[
+  {"request":{"method":"GET","url":"https://example.com/"},
+   "response":{"status":200,"headers":[{"name":":status","value":"200"},{"name":"Server","value":"nginx"}],
+     "content":{"text":"<title>Home</title>"}}},
+  {"request":{"method":"GET","url":"https://example.com/favicon.ico"},
+   "response":{"status":200,"headers":[],"content":{"text":"`+icon+`","encoding":"base64"}}},
+  {"request":{"method":"POST","url":"https://example.com/login","postData":{"text":"u=a"}},
+   "response":{"status":200,"headers":[],"content":{"text":"ok"}}}
+]}}`)
+	items, err := LoadTrafficFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("期望 3 条响应, 实际 %d", len(items))
+	}
+	if string(items[2].Body) != "u=a" || items[0].Body != nil {
+		t.Fatalf("HAR 请求体解析错误: %q %q", items[2].Body, items[0].Body)
+	}
+	home := items[0].Data
+	if home.Title != "Home" || home.Status != 200 || home.URL != "https://example.com/" || home.Headers != "Server: nginx\r\n" {
+		t.Fatalf("HAR 解析错误: %+v", home)
+	}
+	if items[1].Data.Body != "\x00\x01\x02\x03" {
+		t.Fatalf("base64 内容未解码: %q", items[1].Data.Body)
+	}
+}
+
+func TestLoadBurpXML(t *testing.T) {
+	raw := "HTTP/1.1 401 Unauthorized\r\nServer: Apache-Coyote/1.1\r\n\r\n<title>Tomcat Manager</title>"
+	path := writeTraffic(t, "burp.xml", `<?xml version="1.0"?>
+<items burpVersion="2023.1">
+  <item>
+    <url><![CDATA[http://10.0.0.1:8080/manager/html]]></url>
+    <method><![CDATA[GET]]></method>
+    <status>401</status>
+    <request base64="false"><![CDATA[GET /manager/html HTTP/1.1
+Host: 10.0.0.1:8080
+
+probe]]></request>
+    <response base64="true"><![CDATA[`+base64.StdEncoding.EncodeToString([]byte(raw))+`]]></response>
+  </item>
+</items>`)
+	items, err := LoadTrafficFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Data.Status != 401 || items[0].Data.Title != "Tomcat Manager" ||
+		items[0].Data.URL != "http://10.0.0.1:8080/manager/html" || items[0].Method != "GET" || string(items[0].Body) != "probe" {
+		t.Fatalf("Burp XML 解析错误: %+v", items)
+	}
+}
+
+func TestLoadRawTraffic(t *testing.T) {
+	path := writeTraffic(t, "login.txt", "POST /login?x=1 HTTP/1.1\r\nHost: 10.0.0.2\r\nContent-Length: 3\r\n\r\na=1\r\n"+
+		"HTTP/1.1 200 OK\r\nContent-Length: 16\r\n\r\n<title>L</title>")
+	items, err := LoadTrafficFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items[0].Method != "POST" || string(items[0].Body) != "a=1" || items[0].Data.URL != "http://10.0.0.2/login?x=1" || items[0].Data.Title != "L" {
+		t.Fatalf("请求+响应解析错误: %+v %+v", items[0], items[0].Data)
+	}
+
+	path = writeTraffic(t, "only.resp", "HTTP/1.1 200 OK\n\n<title>only</title>")
+	items, err = LoadTrafficFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items[0].Data.URL != "http://only.resp/" || items[0].Data.Title != "only" {
+		t.Fatalf("原始响应解析错误: %+v", items[0].Data)
+	}
+
+	// 请求行是绝对地址时保留协议,没有 Host 头时才用文件名
+	cases := map[string]string{
+		"GET https://10.0.0.3:8443/admin HTTP/1.1\r\nHost: other\r\n\r\n": "https://10.0.0.3:8443/admin",
+		"GET /status HTTP/1.0\r\n\r\n":                                    "http://nohost.txt/status",
+	}
+	for request, want := range cases {
+		path = writeTraffic(t, "nohost.txt", request+"HTTP/1.1 200 OK\r\n\r\nok")
+		items, err = LoadTrafficFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if items[0].Data.URL != want {
+			t.Errorf("期望地址 %s, 实际 %s", want, items[0].Data.URL)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package fingerprints
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScriptSources(t *testing.T) {
+	body := `<script>var inline = 1</script>
+<script type="module" src="/assets/index.4f2a.js"></script>
+<SCRIPT src=vendor.js defer></SCRIPT>
+<script src="/assets/index.4f2a.js"></script>`
+	got := ScriptSources(body)
+	want := []string{"/assets/index.4f2a.js", "vendor.js"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("期望 %v, 实际 %v", want, got)
+	}
+}
+
+func TestSourceMapURL(t *testing.T) {
+	if got := SourceMapURL("a()\n//# sourceMappingURL=old.map\n//# sourceMappingURL=app.js.map\n", ""); got != "app.js.map" {
+		t.Fatalf("应取最后一个注释, 实际 %q", got)
+	}
+	if got := SourceMapURL("a()", "Content-Type: text/javascript\r\nX-SourceMap: /maps/app.map\r\n"); got != "/maps/app.map" {
+		t.Fatalf("应取响应头中的地址, 实际 %q", got)
+	}
+	if got := SourceMapURL("a()", ""); got != "" {
+		t.Fatalf("没有 source map 时应为空, 实际 %q", got)
+	}
+}
+
+func TestSourceMapSummary(t *testing.T) {
+	raw := `{"version":3,"sources":["webpack:///node_modules/element-ui/lib/index.js","webpack:///src/main.js"],
+"sourcesContent":["/*!\n * Element UI v2.15.14\n * (c) ElemeFE\n */\nmodule.exports = {}","/* 普通注释 */ import Vue from 'vue'"]}`
+	ref := "data:application/json;charset=utf-8;base64," + base64.StdEncoding.EncodeToString([]byte(raw))
+	decoded, err := DecodeDataURL(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err := ParseSourceMap(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := sm.Summary()
+	for _, want := range []string{"node_modules/element-ui/lib/index.js", "Element UI v2.15.14\n"} {
+		if !strings.Contains(summary, want) {
+			t.Fatalf("摘要中缺少 %q:\n%s", want, summary)
+		}
+	}
+	if strings.Contains(summary, "普通注释") || strings.Contains(summary, "ElemeFE") {
+		t.Fatalf("摘要只保留版本注释的第一行:\n%s", summary)
+	}
+}
+
+func TestJSSignatures(t *testing.T) {
+	js := `/** @license React v16.13.1
+ * react.production.min.js */
+/*! jQuery v3.6.0 | (c) OpenJS Foundation */ /* 普通注释 */ var a = 1 /* 2 */;`
+	got := JSSignatures(js)
+	want := []string{"@license React v16.13.1", "jQuery v3.6.0 | (c) OpenJS Foundation"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("期望 %v, 实际 %v", want, got)
+	}
+}
+
+// TestJSField js 字段只在脚本内容上匹配,与 body 互不影响
+func TestJSField(t *testing.T) {
+	ast, err := parseExpression(`js="Vue.js v2" && body!="Vue.js"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !UsesField(ast, "js") || UsesField(ast, "header") {
+		t.Fatal("UsesField 结果错误")
+	}
+	data := &ResponseData{Body: `<div id="app"></div>`, JS: "/*! Vue.js v2.6.14 */"}
+	if !ast.Eval(data) {
+		t.Fatal("js 字段应命中")
+	}
+	data.Body, data.JS = "Vue.js v2.6.14", ""
+	if ast.Eval(data) {
+		t.Fatal("body 中的内容不应被 js 字段匹配")
+	}
+}
@@ -0,0 +1,36 @@
+package fingerprints
+
+import "testing"
+
+func TestMergeRules(t *testing.T) {
+	rule := func(name, path string, disable bool) CompiledRule {
+		return CompiledRule{RuleConfig: RuleConfig{Name: name, Path: path, Disable: disable}}
+	}
+	base := []CompiledRule{rule("nginx", "/", false), rule("tomcat", "/", false), rule("tomcat", "/manager", false), rule("iis", "/", false)}
+	overlay := []CompiledRule{rule("tomcat", "/docs", false), rule("iis", "", true), rule("oa", "/oa", false)}
+
+	merged := MergeRules(base, overlay, "nginx")
+	var got []string
+	for _, r := range merged {
+		got = append(got, r.Name+r.Path)
+	}
+	want := []string{"tomcat/docs", "oa/oa"}
+	if len(got) != len(want) {
+		t.Fatalf("期望 %v, 实际 %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("期望 %v, 实际 %v", want, got)
+		}
+	}
+}
+
+func TestLoadBuiltinRules(t *testing.T) {
+	rules, err := LoadBuiltinRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) == 0 || rules[0].Source == "" {
+		t.Fatalf("内置指纹库加载失败: %d 条", len(rules))
+	}
+}
@@ -0,0 +1,109 @@
+package fingerprints
+
+import (
+	"PrintRaptor/source"
+	"strings"
+	"testing"
+)
+
+func TestLintData(t *testing.T) {
+	data := `- name: a
+  path: /
+  expression: body="x" && bodyy="y"
+  ispost: true
+- name: a
+  path: /
+  expression: body="x"
+- name: b
+  expression: ""
+`
+	issues := LintData("rules.yaml", []byte(data))
+	want := []struct {
+		line, column int
+		contains     string
+	}{
+		{3, 27, "bodyy"},
+		{4, 3, "isPost"},
+		{5, 9, "规则重复"},
+		{8, 3, "缺少 expression"},
+	}
+	for _, w := range want {
+		found := false
+		for _, issue := range issues {
+			if issue.Line == w.line && issue.Column == w.column && strings.Contains(issue.Message, w.contains) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("缺少 %d:%d %q 的问题, 实际: %v", w.line, w.column, w.contains, issues)
+		}
+	}
+	if !HasLintErrors(issues, false) {
+		t.Errorf("应当存在 error 级别的问题")
+	}
+}
+
+func TestLintSyntaxError(t *testing.T) {
+	issues := LintData("rules.yaml", []byte("- name: a\n  expression: \"body=\n"))
+	if len(issues) != 1 || issues[0].Line != 2 {
+		t.Fatalf("yaml 语法错误未正确定位: %v", issues)
+	}
+}
+
+// TestLintEmbedded 内置的指纹库、服务指纹和WAF规则都不能有 error 级别的问题
+func TestLintEmbedded(t *testing.T) {
+	seen := make(map[string]seenRule)
+	for name, data := range map[string][]byte{
+		source.FingerName:  source.Finger,
+		source.ServiceName: source.Service,
+		source.WafName:     source.Waf,
+	} {
+		for _, issue := range lintData(name, data, seen) {
+			if issue.Level == LintError {
+				t.Errorf("%s", issue)
+			}
+		}
+	}
+}
+
+// TestLintBlockVariant WAF 规则的识别条目和同名的拦截页条目不算重复
+func TestLintBlockVariant(t *testing.T) {
+	data := `- name: waf
+  expression: header="X-Waf"
+- name: waf
+  block: true
+  expression: body="blocked"
+- name: waf
+  expression: header="X-Other"
+`
+	issues := LintData("waf.yaml", []byte(data))
+	if len(issues) != 1 || issues[0].Line != 6 || issues[0].Level != LintError {
+		t.Fatalf("期望只有第 6 行的重复规则报错, 实际 %v", issues)
+	}
+}
+
+// TestLintSampleFields 样本支持 banner 和 status,status 必须是整数
+func TestLintSampleFields(t *testing.T) {
+	data := `- name: a
+  expression: status="403"
+  tests:
+    match:
+      - status: 403
+        banner: "x"
+    noMatch:
+      - status: forbidden
+`
+	issues := LintData("rules.yaml", []byte(data))
+	if len(issues) != 1 || issues[0].Line != 8 || !strings.Contains(issues[0].Message, "status") {
+		t.Fatalf("期望只在第 8 行报 status 类型错误, 实际: %v", issues)
+	}
+}
+
+// TestLintColumnMultibyte 表达式中有中文时列号按字符计算
+func TestLintColumnMultibyte(t *testing.T) {
+	issues := LintData("rules.yaml", []byte("- name: a\n  expression: body=\"登录\" && bodyy=\"y\"\n"))
+	if len(issues) != 1 || issues[0].Line != 2 || issues[0].Column != 28 {
+		t.Fatalf("期望在 2:28 报错, 实际: %v", issues)
+	}
+}
@@ -0,0 +1,63 @@
+package fingerprints
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRulesFromPaths(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("common.yaml", "- name: nginx\n  path: /\n  expression: header=\"nginx\"\n")
+	write("special/oa.yml", "- name: oa\n  path: /oa/login\n  expression: body=\"oa\"\n- name: nginx\n  path: /status\n  expression: body=\"nginx\"\n")
+	write("special/readme.txt", "not a rule file")
+
+	rules, err := LoadRulesFromPaths([]string{filepath.Join(dir, "*.yaml"), dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("期望加载 3 条规则, 实际 %d", len(rules))
+	}
+	if rules[1].Source != filepath.Join(dir, "special", "oa.yml") || rules[1].Line != 1 || rules[2].Line != 4 {
+		t.Errorf("规则来源记录错误: %s:%d %s:%d", rules[1].Source, rules[1].Line, rules[2].Source, rules[2].Line)
+	}
+	dups := FindDuplicateRules(rules)
+	if len(dups) != 1 || dups[0].Name != "nginx" || len(dups[0].Rules) != 2 {
+		t.Errorf("跨文件重名检测错误: %v", dups)
+	}
+}
+
+// TestLoadHexPayload payloadHex 和 bannerHex 按原始字节处理,非法十六进制和两种 payload 同时写都报错
+func TestLoadHexPayload(t *testing.T) {
+	data := "- name: rdp\n  protocol: tcp\n  payloadHex: 03 00 0e e0\n  expression: bannerHex=\"0300 0ed0\"\n"
+	rules, err := LoadRulesFromBytes("rules.yaml", []byte(data))
+	if err != nil || len(rules) != 1 {
+		t.Fatalf("规则加载失败: %v %v", rules, err)
+	}
+	if rules[0].Payload != "\x03\x00\x0e\xe0" {
+		t.Errorf("payloadHex 解码错误: % x", rules[0].Payload)
+	}
+	if !rules[0].AST.Eval(&ResponseData{Banner: "\x03\x00\x0e\xd0\x00"}) {
+		t.Error("bannerHex 应按字节前缀命中")
+	}
+	if rules[0].AST.Eval(&ResponseData{Banner: "\x00\x03\x00\x0e\xd0"}) {
+		t.Error("bannerHex 只匹配开头")
+	}
+	if _, err := parseExpression(`bannerHex="0g"`); err == nil {
+		t.Error("非法十六进制应当报错")
+	}
+	both := "- name: x\n  protocol: tcp\n  payload: a\n  payloadHex: \"61\"\n  expression: banner=\"a\"\n"
+	if issues := LintData("rules.yaml", []byte(both)); !HasLintErrors(issues, false) {
+		t.Errorf("payload 和 payloadHex 同时写应当报错: %v", issues)
+	}
+}
@@ -0,0 +1,47 @@
+package fingerprints
+
+import "testing"
+
+func TestRequestOptions(t *testing.T) {
+	data := `- name: api
+  path: /api/v1/status
+  method: put
+  contentType: application/json
+  headers:
+    X-Requested-With: XMLHttpRequest
+  body: '{"probe":true}'
+  expression: body="version"
+- name: bad-method
+  method: TRACE
+  expression: body="x"
+- name: bad-body
+  method: POST
+  bodyBase64: '!!!'
+  expression: body="x"
+`
+	rules, err := LoadRulesFromBytes("rules.yaml", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 {
+		t.Fatalf("非法的请求参数应当被跳过, 实际加载 %d 条", len(rules))
+	}
+	rule := rules[0]
+	if rule.HTTPMethod(rule.IsPost) != "PUT" || rule.Headers["X-Requested-With"] != "XMLHttpRequest" {
+		t.Errorf("请求参数解析错误: %+v", rule.RequestOptions)
+	}
+	if body, ok := rule.RequestBody(); !ok || string(body) != `{"probe":true}` {
+		t.Errorf("请求体错误: %q", body)
+	}
+	legacy := RequestOptions{}
+	if legacy.HTTPMethod(true) != "POST" || legacy.Key() != "" {
+		t.Errorf("isPost 兼容错误")
+	}
+	expanded := RequestOptions{Body: "token={{t}}"}.Expand(map[string]string{"t": "abc"})
+	if expanded.Body != "token=abc" {
+		t.Errorf("请求体变量替换错误: %s", expanded.Body)
+	}
+	if issues := LintData("rules.yaml", []byte(data)); len(issues) != 2 {
+		t.Errorf("lint 应当报告 2 个请求参数错误, 实际: %v", issues)
+	}
+}
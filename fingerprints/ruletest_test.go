@@ -0,0 +1,78 @@
+package fingerprints
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestRunRuleTests(t *testing.T) {
+	dir := t.TempDir()
+	raw := "HTTP/1.1 200 OK\r\nServer: nginx\r\n\r\n<title>demo</title>hello"
+	if err := os.WriteFile(filepath.Join(dir, "demo.http"), []byte(raw), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ast, err := parseExpression(`header="nginx" && body="hello"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := []CompiledRule{{
+		RuleConfig: RuleConfig{
+			Name: "demo",
+			Tests: &RuleTests{
+				Match:   []RuleSample{{File: "demo.http"}, {Body: "hello"}},
+				NoMatch: []RuleSample{{Header: "Server: nginx", Body: "bye"}, {File: "missing.http"}},
+			},
+		},
+		AST:    ast,
+		Source: filepath.Join(dir, "rules.yaml"),
+	}}
+	report := RunRuleTests(rules)
+	if report.Rules != 1 || report.Samples != 4 {
+		t.Fatalf("统计错误: %+v", report)
+	}
+	// match[1] 只有body,header不满足;noMatch[1] 文件不存在
+	if len(report.Failures) != 2 {
+		t.Fatalf("期望 2 个失败, 实际: %v", report.Failures)
+	}
+	if report.Failures[0].Sample != "match[1]" || report.Failures[1].Err == nil {
+		t.Errorf("失败样本不符合预期: %v", report.Failures)
+	}
+}
+
+// TestRuleSampleFields 样本可以直接写 banner 和 status,用来测试服务指纹和按状态码匹配的规则
+func TestRuleSampleFields(t *testing.T) {
+	var sample RuleSample
+	if err := yaml.Unmarshal([]byte("banner: \"SSH-2.0-OpenSSH_8.9\"\nstatus: 403\n"), &sample); err != nil {
+		t.Fatal(err)
+	}
+	data, err := sample.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := parseExpression(`banner="OpenSSH" && status="403"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ast.Eval(data) {
+		t.Fatalf("banner 和 status 样本应当命中, 实际样本 %+v", data)
+	}
+}
+
+// TestRunRuleTestsDisabled 带样本的禁用条目直接跳过,不能拿空表达式求值
+func TestRunRuleTestsDisabled(t *testing.T) {
+	data := "- name: demo\n  disable: true\n  tests:\n    match:\n      - body: \"hello\"\n"
+	rules, err := LoadRulesFromBytes("rules.yaml", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || !rules[0].Disable {
+		t.Fatalf("期望保留一条禁用条目, 实际 %+v", rules)
+	}
+	report := RunRuleTests(rules)
+	if report.Rules != 0 || report.Samples != 0 || len(report.Failures) != 0 {
+		t.Fatalf("禁用条目不应参与测试: %+v", report)
+	}
+}
@@ -0,0 +1,41 @@
+package fingerprints
+
+import "testing"
+
+func TestStepRule(t *testing.T) {
+	data := `- name: demo-chain
+  steps:
+    - path: /
+      extract:
+        entry: 'src="(/static/[a-z0-9]+)/app\.js"'
+        session: 'header:Set-Cookie: (SID=[A-Z0-9]+)'
+    - path: "{{entry}}/version.txt"
+      expression: body="demo"
+`
+	rules, err := LoadRulesFromBytes("rules.yaml", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || len(rules[0].Steps) != 2 || rules[0].AST == nil {
+		t.Fatalf("多步规则加载错误: %+v", rules)
+	}
+	vars := make(map[string]string)
+	first := &ResponseData{Headers: "Set-Cookie: SID=AB12\r\n", Body: `<script src="/static/9f3a/app.js"></script>`}
+	if missing := rules[0].Steps[0].ExtractVars(first, vars); len(missing) != 0 {
+		t.Fatalf("变量提取失败: %v", missing)
+	}
+	if got := ExpandVars(rules[0].Steps[1].Path, vars); got != "/static/9f3a/version.txt" {
+		t.Errorf("变量替换错误: %s", got)
+	}
+	if vars["session"] != "SID=AB12" {
+		t.Errorf("响应头变量提取错误: %v", vars)
+	}
+	if ExpandVars("/{{unknown}}", vars) != "/{{unknown}}" {
+		t.Errorf("未定义变量应保持原样")
+	}
+
+	bad := "- name: bad\n  steps:\n    - path: /\n      extract:\n        x: '('\n"
+	if issues := LintData("rules.yaml", []byte(bad)); len(issues) != 2 {
+		t.Errorf("期望报告正则错误和缺少 expression, 实际: %v", issues)
+	}
+}
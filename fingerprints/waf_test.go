@@ -0,0 +1,48 @@
+package fingerprints
+
+import (
+	"PrintRaptor/source"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDetectWaf(t *testing.T) {
+	rules, err := LoadWafRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Count(string(source.Waf), "\n- name:"); len(rules) != want {
+		t.Fatalf("内置WAF规则有 %d 条未能编译", want-len(rules))
+	}
+
+	header := http.Header{}
+	header.Set("Server", "cloudflare")
+	header.Set("Cf-Ray", "8a1b2c3d4e5f-HKG")
+	page := NewResponseData("example.com", header, []byte("<title>Home</title>"))
+	page.Status = 200
+	hits, blocked := DetectWaf(rules, page)
+	if len(hits) != 1 || hits[0].Name != "Cloudflare" || hits[0].Kind != "cdn" || blocked {
+		t.Fatalf("期望识别出 Cloudflare 且不是拦截页, 实际 %+v blocked=%v", hits, blocked)
+	}
+
+	page = NewResponseData("example.com", header, []byte(`<div id="cf-error-details">Sorry, you have been blocked</div>`))
+	page.Status = 403
+	if _, blocked := DetectWaf(rules, page); !blocked {
+		t.Fatal("403 的 Cloudflare 错误页应识别为拦截页")
+	}
+	page.Status = 200
+	if _, blocked := DetectWaf(rules, page); blocked {
+		t.Fatal("status 应按整个值比较")
+	}
+}
+
+func TestStatusCondition(t *testing.T) {
+	ast, err := parseExpression(`status="403" && status!="40"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ast.Eval(&ResponseData{Status: 403}) || ast.Eval(&ResponseData{Status: 404}) {
+		t.Fatal("status 条件求值错误")
+	}
+}
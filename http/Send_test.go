@@ -5,6 +5,7 @@ import (
 	"PrintRaptor/fingerprints"
 	"fmt"
 	"log"
+	"net/url"
 	"testing"
 )
 
@@ -16,7 +17,7 @@ func TestSend(t *testing.T) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	targetUrl := "http://localhost:8080/"
+	targetUrl, _ := url.Parse("http://localhost:8080/")
 	for _, rule := range rules {
 		target, _ := NewTarget(targetUrl, &rule)
 		banner, err := target.Request()
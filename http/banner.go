@@ -0,0 +1,83 @@
+package http
+
+import (
+	"PrintRaptor/config"
+	"PrintRaptor/fingerprints"
+	"PrintRaptor/models"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+// maxBannerSize banner 最多读取的字节数
+const maxBannerSize = 8192
+
+// bannerIdleTimeout 读到第一段数据后,再等这么久没有新数据就认为banner读完了
+const bannerIdleTimeout = 500 * time.Millisecond
+
+// Probe 非HTTP服务的banner探测: 建连,有 payload 时先发送,再读取服务端返回的数据
+// tcp 会按config走代理,udp 代理无法转发,始终直连
+func (target *Target) Probe() (*models.Banner, error) {
+	return target.ProbeContext(context.Background())
+}
+
+// ProbeContext 同 Probe,ctx 取消后中断限速等待和建连
+func (target *Target) ProbeContext(ctx context.Context) (*models.Banner, error) {
+	banner := &models.Banner{CompiledRule: target.CompiledRule}
+	banner.ResponseData = &fingerprints.ResponseData{Host: target.U.Host, URL: target.U.String()}
+	if err := waitTurn(ctx, target.U.Host); err != nil {
+		return banner, err
+	}
+	timeout := config.GetTimeOut()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	if target.U.Scheme == fingerprints.ProtocolUDP {
+		conn, err = (&net.Dialer{Timeout: timeout}).DialContext(ctx, "udp", target.U.Host)
+	} else {
+		conn, err = dialContext(ctx, "tcp", target.U.Host)
+	}
+	if err != nil {
+		return banner, err
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	if payload := target.CompiledRule.Payload; payload != "" {
+		if _, err := conn.Write([]byte(payload)); err != nil {
+			return banner, err
+		}
+	}
+	data, err := readBanner(conn)
+	banner.ResponseData.Banner = string(data)
+	banner.ResponseData.BodyLength = len(data)
+	if len(data) > 0 {
+		// 读到了数据,超时或连接关闭都不算错误
+		return banner, nil
+	}
+	return banner, err
+}
+
+// readBanner 一直读到连接关闭、超过上限或者空闲超时
+func readBanner(conn net.Conn) ([]byte, error) {
+	buf := make([]byte, 0, 1024)
+	chunk := make([]byte, 1024)
+	for len(buf) < maxBannerSize {
+		n, err := conn.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) && len(buf) > 0 {
+				return buf, nil
+			}
+			return buf, err
+		}
+		if n > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(bannerIdleTimeout))
+		}
+	}
+	return buf[:maxBannerSize], nil
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"PrintRaptor/config"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+所有请求共用一个 http.Client,连接按主机复用(keep-alive / HTTP/2),
+避免每条规则都重新做一次 TCP 和 TLS 握手
+*/
+
+var (
+	sharedClient    *http.Client
+	sharedClientErr error
+	clientOnce      sync.Once
+)
+
+// getClient 按config构造的共享 client,第一次使用时创建
+func getClient() (*http.Client, error) {
+	clientOnce.Do(func() {
+		transport, err := config.GetProxy()
+		if err != nil {
+			sharedClientErr = err
+			return
+		}
+		sharedClient = &http.Client{Transport: transport}
+	})
+	return sharedClient, sharedClientErr
+}
+
+// do 用共享 client 发送请求,每个请求从代理池分配一个代理(context 中用 config.WithProxy 指定了则用指定的),
+// 连不上代理时据此摘除
+func do(req *http.Request) (*http.Response, error) {
+	client, err := clientFor(req)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := config.GetProxyPool()
+	if err != nil {
+		return nil, err
+	}
+	proxyURL, ok := config.ProxyFromContext(req.Context())
+	if !ok {
+		proxyURL = pool.Next()
+		req = req.WithContext(config.WithProxy(req.Context(), proxyURL))
+	}
+	resp, err := client.Do(req)
+	if proxyURL != nil {
+		if config.IsProxyError(err) {
+			pool.MarkFailed(proxyURL)
+		} else if err == nil {
+			pool.MarkOK(proxyURL)
+		}
+	}
+	return resp, err
+}
+
+// readBody 读取响应体,最多 limit 字节;timeout 内没读完就调用 abort 中断读取
+// 超出上限或被中断时返回已读到的部分,truncated 为 true
+func readBody(body io.Reader, limit int, timeout time.Duration, abort func()) (data []byte, truncated bool) {
+	timer := time.AfterFunc(timeout, abort)
+	defer timer.Stop()
+	data, err := io.ReadAll(io.LimitReader(body, int64(limit)+1))
+	if len(data) > limit {
+		return data[:limit], true
+	}
+	return data, err != nil
+}
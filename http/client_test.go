@@ -0,0 +1,71 @@
+package http
+
+import (
+	"PrintRaptor/fingerprints"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSharedClientReusesConnections 多次请求(含 favicon)应复用同一个连接
+func TestSharedClientReusesConnections(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<title>ok</title>"))
+	}))
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	for _, path := range []string{"/", "/login", "/admin"} {
+		rule := &fingerprints.CompiledRule{RuleConfig: fingerprints.RuleConfig{Name: "t", Path: path}}
+		target, _ := NewTarget(u, rule)
+		banner, err := target.Request()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if banner.ResponseData.Title != "ok" {
+			t.Fatalf("unexpected title %q", banner.ResponseData.Title)
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Fatalf("期望复用1个连接, 实际建立了 %d 个", n)
+	}
+}
+
+func TestReadBodyLimit(t *testing.T) {
+	data, truncated := readBody(strings.NewReader(strings.Repeat("a", 100)), 10, time.Second, func() {})
+	if len(data) != 10 || !truncated {
+		t.Fatalf("期望截断为10字节, 实际 %d 字节 truncated=%v", len(data), truncated)
+	}
+	data, truncated = readBody(strings.NewReader("abc"), 10, time.Second, func() {})
+	if string(data) != "abc" || truncated {
+		t.Fatalf("未超限不应截断: %q truncated=%v", data, truncated)
+	}
+}
+
+// TestReadBodyTimeout 慢速返回的响应体超时后保留已读到的部分
+func TestReadBodyTimeout(t *testing.T) {
+	r, w := io.Pipe()
+	go w.Write([]byte("partial"))
+	start := time.Now()
+	data, truncated := readBody(r, 1024, 50*time.Millisecond, func() { r.CloseWithError(context.DeadlineExceeded) })
+	if string(data) != "partial" || !truncated {
+		t.Fatalf("期望保留已读部分并标记截断: %q truncated=%v", data, truncated)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("读取响应体没有按时中断")
+	}
+}
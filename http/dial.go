@@ -0,0 +1,140 @@
+package http
+
+import (
+	"PrintRaptor/config"
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/net/proxy"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+/*
+建立到目标的原始TCP连接,raw请求、banner探测等不经过 http.Client 的场景使用
+http/https 代理通过 CONNECT 建立隧道,socks5 代理交给 x/net/proxy 处理
+*/
+
+// dialContext 从代理池取一个代理(context 中用 config.WithProxy 指定了则用指定的)连接 addr(host:port)
+func dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	pool, err := config.GetProxyPool()
+	if err != nil {
+		return nil, err
+	}
+	proxyURL, ok := config.ProxyFromContext(ctx)
+	if !ok {
+		proxyURL = pool.Next()
+	}
+	conn, err := dialVia(ctx, proxyURL, network, addr)
+	if proxyURL != nil {
+		if config.IsProxyError(err) {
+			pool.MarkFailed(proxyURL)
+		} else if err == nil {
+			pool.MarkOK(proxyURL)
+		}
+	}
+	return conn, err
+}
+
+// proxyForward 连接代理本身使用的拨号器,失败时包装成 proxyconnect 错误,与 net/http 的行为一致
+type proxyForward struct {
+	*net.Dialer
+}
+
+func (f proxyForward) Dial(network, addr string) (net.Conn, error) {
+	return f.DialContext(context.Background(), network, addr)
+}
+
+func (f proxyForward) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := f.Dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, &net.OpError{Op: "proxyconnect", Net: network, Err: err}
+	}
+	return conn, nil
+}
+
+// dialVia 经由指定代理连接 addr,proxyURL 为nil时直连
+func dialVia(ctx context.Context, proxyURL *url.URL, network, addr string) (net.Conn, error) {
+	direct := &net.Dialer{Timeout: config.GetDialTimeOut()}
+	if proxyURL == nil {
+		return direct.DialContext(ctx, network, addr)
+	}
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		dialer, err := proxy.FromURL(proxyURL, proxyForward{direct})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SOCKS5 dialer: %v", err)
+		}
+		if cd, ok := dialer.(proxy.ContextDialer); ok {
+			return cd.DialContext(ctx, network, addr)
+		}
+		return dialer.Dial(network, addr)
+	default:
+		return dialConnect(ctx, proxyForward{direct}, proxyURL, addr)
+	}
+}
+
+// dialConnect 通过 http/https 代理的 CONNECT 方法建立隧道
+func dialConnect(ctx context.Context, forward proxyForward, proxyURL *url.URL, addr string) (net.Conn, error) {
+	// 端口已由 config.ParseProxy 补全
+	conn, err := forward.DialContext(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname(), InsecureSkipVerify: true})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer conn.SetDeadline(noDeadline)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT %s failed: %s", addr, resp.Status)
+	}
+	// 先发数据的服务(SSH、FTP等)的banner可能和 200 一起被读进了缓冲区
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn 读取时先取完解析 CONNECT 响应时多读的数据
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
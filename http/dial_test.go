@@ -0,0 +1,62 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// serveConnectProxy 起一个 HTTP CONNECT 代理,隧道建立后把连接交给 tunnel 处理
+func serveConnectProxy(t *testing.T, tunnel func(client net.Conn, reader *bufio.Reader, addr string)) *url.URL {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				req, err := http.ReadRequest(reader)
+				if err != nil || req.Method != http.MethodConnect {
+					return
+				}
+				tunnel(conn, reader, req.Host)
+			}()
+		}
+	}()
+	u, _ := url.Parse("http://" + ln.Addr().String())
+	return u
+}
+
+// TestDialConnectBufferedBanner 代理把 200 和服务端先发的banner一次写回时,banner 不能丢
+func TestDialConnectBufferedBanner(t *testing.T) {
+	proxyURL := serveConnectProxy(t, func(client net.Conn, _ *bufio.Reader, _ string) {
+		client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\nSSH-2.0-OpenSSH_8.9\r\n"))
+		time.Sleep(100 * time.Millisecond)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	conn, err := dialVia(ctx, proxyURL, "tcp", "10.0.0.1:22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if line != "SSH-2.0-OpenSSH_8.9\r\n" {
+		t.Fatalf("banner 丢失, 读到 %q", line)
+	}
+}
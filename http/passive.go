@@ -0,0 +1,219 @@
+package http
+
+import (
+	"PrintRaptor/config"
+	"PrintRaptor/fingerprints"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*
+被动识别代理
+浏览器把代理指向这里,明文HTTP请求照常转发给目标(上游同样走config中的代理池),
+每个响应转换成 ResponseData 交给 OnResponse 在后台匹配;
+HTTPS 的 CONNECT 只做透明转发,不解密,因此无法识别
+*/
+
+// hopHeaders 逐跳头部,转发时去掉
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// PassiveProxy 转发流量并把响应交给 OnResponse
+type PassiveProxy struct {
+	// OnResponse 每个完整读取到的响应调用一次,method 为请求方法;调用方需要自行保证不阻塞
+	OnResponse func(method string, data *fingerprints.ResponseData)
+}
+
+func (p *PassiveProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodConnect {
+		p.tunnel(w, req)
+		return
+	}
+	if !req.URL.IsAbs() {
+		http.Error(w, "PrintRaptor 被动代理只接受代理请求", http.StatusBadRequest)
+		return
+	}
+	client, err := getClient()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	out := req.Clone(req.Context())
+	out.RequestURI = ""
+	removeHopHeaders(out.Header)
+	// 只让上游返回能解压的编码,否则响应体无法匹配;都去掉后由 transport 自己协商 gzip 并解压
+	if accept := out.Header.Get("Accept-Encoding"); accept != "" {
+		if kept := decodableEncodings(accept); kept != "" {
+			out.Header.Set("Accept-Encoding", kept)
+		} else {
+			out.Header.Del("Accept-Encoding")
+		}
+	}
+	// 直接用 transport,重定向原样交还给浏览器
+	resp, err := client.Transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	removeHopHeaders(resp.Header)
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	// 边转发边保留一份用于匹配,超过上限的部分只转发不保留
+	limit := config.GetMaxBodySize()
+	capture := &limitedBuffer{limit: limit}
+	_, copyErr := io.Copy(w, io.TeeReader(resp.Body, capture))
+	if p.OnResponse == nil {
+		return
+	}
+	data := fingerprints.NewResponseData(req.URL.Host, resp.Header, decodeBody(resp.Header, capture.buf, limit))
+	data.Status = resp.StatusCode
+	data.URL = req.URL.String()
+	data.Truncated = capture.truncated || copyErr != nil
+	p.OnResponse(req.Method, data)
+}
+
+// tunnel HTTPS 等 CONNECT 请求直接建立双向转发
+func (p *PassiveProxy) tunnel(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), config.GetDialTimeOut())
+	defer cancel()
+	upstream, err := dialContext(ctx, "tcp", req.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "不支持 CONNECT", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	client, _, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	go pipe(upstream, client)
+	go pipe(client, upstream)
+}
+
+func pipe(dst, src net.Conn) {
+	defer dst.Close()
+	defer src.Close()
+	_, _ = io.Copy(dst, src)
+}
+
+func removeHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			header.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+// decodableEncodings 浏览器声明的 Accept-Encoding 中只保留 decodeBody 能解压的编码,br、zstd 等标准库没有解码器
+func decodableEncodings(accept string) string {
+	var kept []string
+	for _, item := range strings.Split(accept, ",") {
+		item = strings.TrimSpace(item)
+		name, _, _ := strings.Cut(item, ";")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gzip", "x-gzip", "deflate", "identity":
+			kept = append(kept, item)
+		}
+	}
+	return strings.Join(kept, ", ")
+}
+
+// decodeBody 浏览器自己声明了 Accept-Encoding 时响应体原样压缩转发,匹配前需要解压
+func decodeBody(header http.Header, body []byte, limit int) []byte {
+	var reader io.ReadCloser
+	var err error
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// 按规范是 zlib 格式,也有服务器直接发裸的 deflate 流
+		reader, err = zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			reader, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	default:
+		return body
+	}
+	if err != nil {
+		return body
+	}
+	defer reader.Close()
+	// 截断的压缩流解压到哪算哪
+	decoded, _ := io.ReadAll(io.LimitReader(reader, int64(limit)))
+	return decoded
+}
+
+// limitedBuffer 只保留前 limit 字节
+type limitedBuffer struct {
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - len(b.buf); room > 0 {
+		if len(p) > room {
+			b.buf = append(b.buf, p[:room]...)
+			b.truncated = true
+		} else {
+			b.buf = append(b.buf, p...)
+		}
+	} else if len(p) > 0 {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+// passiveShutdownTimeout 退出时等待进行中的请求转发完成的最长时间
+const passiveShutdownTimeout = 5 * time.Second
+
+// ListenPassive 在 addr 上启动被动代理,ctx 取消后关闭
+func ListenPassive(ctx context.Context, addr string, proxy *PassiveProxy) error {
+	server := &http.Server{Addr: addr, Handler: proxy, ReadHeaderTimeout: 30 * time.Second}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		// 等正在转发的请求处理完再返回,之后才能安全地停止匹配
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), passiveShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			server.Close()
+		}
+	}()
+	log.Printf("被动识别代理已启动: %s,把浏览器的HTTP代理指向该地址", addr)
+	err := server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		// Shutdown 时 ListenAndServe 立即返回,handler 可能还在运行
+		<-shutdown
+		return nil
+	}
+	return err
+}
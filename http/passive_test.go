@@ -0,0 +1,59 @@
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// TestDecodeBody gzip、zlib 格式和裸 deflate 的响应体都要解压,未知编码原样返回
+func TestDecodeBody(t *testing.T) {
+	const body = "<title>passive</title>"
+	compress := func(newWriter func(io.Writer) io.WriteCloser) []byte {
+		var buf bytes.Buffer
+		w := newWriter(&buf)
+		w.Write([]byte(body))
+		w.Close()
+		return buf.Bytes()
+	}
+	cases := []struct {
+		encoding string
+		data     []byte
+		want     string
+	}{
+		{"gzip", compress(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }), body},
+		{"deflate", compress(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }), body},
+		{"Deflate", compress(func(w io.Writer) io.WriteCloser { fw, _ := flate.NewWriter(w, flate.DefaultCompression); return fw }), body},
+		{"", []byte(body), body},
+		{"br", []byte("\x0b\x02"), "\x0b\x02"},
+	}
+	for _, c := range cases {
+		header := http.Header{}
+		if c.encoding != "" {
+			header.Set("Content-Encoding", c.encoding)
+		}
+		if got := string(decodeBody(header, c.data, 1024)); got != c.want {
+			t.Errorf("%q: 期望 %q, 实际 %q", c.encoding, c.want, got)
+		}
+	}
+}
+
+// TestDecodableEncodings 转发给上游时去掉无法解压的编码
+func TestDecodableEncodings(t *testing.T) {
+	cases := map[string]string{
+		"gzip, deflate, br, zstd": "gzip, deflate",
+		"br;q=1.0, gzip;q=0.8":    "gzip;q=0.8",
+		"br":                      "",
+		"*":                       "",
+		"identity":                "identity",
+	}
+	for accept, want := range cases {
+		if got := decodableEncodings(accept); got != want {
+			t.Errorf("%q: 期望 %q, 实际 %q", accept, want, got)
+		}
+	}
+}
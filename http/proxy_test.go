@@ -0,0 +1,156 @@
+package http
+
+import (
+	"PrintRaptor/config"
+	"PrintRaptor/fingerprints"
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// tunnel 在 client 和 addr 之间双向转发,reader 为 client 上已经带缓冲的读取端
+func tunnel(client net.Conn, reader io.Reader, addr string) {
+	upstream, err := net.Dial("tcp", addr)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	go io.Copy(upstream, reader)
+	io.Copy(client, upstream)
+}
+
+// serveSocks5 起一个不需要认证的 SOCKS5 代理,connects 记录建立过的隧道数
+func serveSocks5(t *testing.T, connects *int32) *url.URL {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				// 协商: VER NMETHODS METHODS...,回复不需要认证
+				head := make([]byte, 2)
+				if _, err := io.ReadFull(reader, head); err != nil || head[0] != 5 {
+					return
+				}
+				if _, err := io.ReadFull(reader, make([]byte, head[1])); err != nil {
+					return
+				}
+				conn.Write([]byte{5, 0})
+				// 请求: VER CMD RSV ATYP DST.ADDR DST.PORT
+				req := make([]byte, 4)
+				if _, err := io.ReadFull(reader, req); err != nil || req[1] != 1 {
+					return
+				}
+				var host string
+				switch req[3] {
+				case 1, 4:
+					ip := make(net.IP, map[byte]int{1: 4, 4: 16}[req[3]])
+					if _, err := io.ReadFull(reader, ip); err != nil {
+						return
+					}
+					host = ip.String()
+				case 3:
+					n, err := reader.ReadByte()
+					if err != nil {
+						return
+					}
+					name := make([]byte, n)
+					if _, err := io.ReadFull(reader, name); err != nil {
+						return
+					}
+					host = string(name)
+				default:
+					return
+				}
+				port := make([]byte, 2)
+				if _, err := io.ReadFull(reader, port); err != nil {
+					return
+				}
+				addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+				atomic.AddInt32(connects, 1)
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				tunnel(conn, reader, addr)
+			}()
+		}
+	}()
+	u, _ := url.Parse("socks5://" + ln.Addr().String())
+	return u
+}
+
+// TestRequestViaProxy 经由 SOCKS5 和 HTTP CONNECT 代理完整走一遍请求,普通请求和 raw 请求都要经过代理
+func TestRequestViaProxy(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "behind-proxy")
+		w.Write([]byte("<title>proxied</title>" + r.URL.Path))
+	})
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+
+	var socksConnects, httpConnects int32
+	socksURL := serveSocks5(t, &socksConnects)
+	connectURL := serveConnectProxy(t, func(client net.Conn, reader *bufio.Reader, addr string) {
+		atomic.AddInt32(&httpConnects, 1)
+		client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		tunnel(client, reader, addr)
+	})
+
+	cases := []struct {
+		name     string
+		proxy    *url.URL
+		target   string
+		connects *int32
+	}{
+		{"socks5", socksURL, plain.URL, &socksConnects},
+		{"connect", connectURL, secure.URL, &httpConnects},
+	}
+	for _, c := range cases {
+		u, _ := url.Parse(c.target)
+		ctx := config.WithProxy(context.Background(), c.proxy)
+
+		before := atomic.LoadInt32(c.connects)
+		target, _ := NewTarget(u, &fingerprints.CompiledRule{RuleConfig: fingerprints.RuleConfig{Path: "/via"}})
+		banner, err := target.RequestContext(ctx)
+		if err != nil {
+			t.Fatalf("%s: 经代理请求失败: %v", c.name, err)
+		}
+		if data := banner.ResponseData; data.Title != "proxied" || !strings.Contains(data.Body, "/via") {
+			t.Errorf("%s: 响应不符合预期: %+v", c.name, data)
+		}
+		if atomic.LoadInt32(c.connects) == before {
+			t.Errorf("%s: 请求没有经过代理", c.name)
+		}
+
+		before = atomic.LoadInt32(c.connects)
+		raw := &fingerprints.CompiledRule{RuleConfig: fingerprints.RuleConfig{Raw: "GET /raw HTTP/1.0\nHost: {{Hostname}}\n\n"}}
+		target, _ = NewTarget(u, raw)
+		banner, err = target.RequestContext(ctx)
+		if err != nil {
+			t.Fatalf("%s: 经代理发送 raw 请求失败: %v", c.name, err)
+		}
+		if data := banner.ResponseData; !strings.Contains(data.Headers, "behind-proxy") || !strings.Contains(data.Body, "/raw") {
+			t.Errorf("%s: raw 响应不符合预期: %+v", c.name, data)
+		}
+		if atomic.LoadInt32(c.connects) == before {
+			t.Errorf("%s: raw 请求没有经过代理", c.name)
+		}
+	}
+}
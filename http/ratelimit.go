@@ -0,0 +1,127 @@
+package http
+
+import (
+	"PrintRaptor/config"
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+/*
+发包限速
+全局和每个主机各一个令牌桶,发每个请求(含重试、favicon、raw、banner探测)前都要拿到两边的令牌,
+再随机等待一小段时间,避免固定间隔的请求被 WAF 识别
+*/
+
+// tokenBucket 令牌桶,rate 为每秒生成的令牌数,rate<=0 表示不限速
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve 取走一个令牌,返回需要等待多久令牌才可用
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Wait 阻塞到拿到令牌,ctx 取消时提前返回错误
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	if b == nil || b.rate <= 0 {
+		return nil
+	}
+	return sleep(ctx, b.reserve())
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimiter 全局令牌桶 + 按主机的令牌桶 + 随机延迟
+type rateLimiter struct {
+	cfg    config.RateLimit
+	global *tokenBucket
+	mu     sync.Mutex
+	hosts  map[string]*tokenBucket
+}
+
+func newRateLimiter(cfg config.RateLimit) *rateLimiter {
+	return &rateLimiter{
+		cfg:    cfg,
+		global: newTokenBucket(cfg.Global, cfg.Burst),
+		hosts:  make(map[string]*tokenBucket),
+	}
+}
+
+func (l *rateLimiter) host(host string) *tokenBucket {
+	if l.cfg.PerHost <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, ok := l.hosts[host]
+	if !ok {
+		bucket = newTokenBucket(l.cfg.PerHost, l.cfg.Burst)
+		l.hosts[host] = bucket
+	}
+	return bucket
+}
+
+// Wait 发往 host 的请求发出前调用
+func (l *rateLimiter) Wait(ctx context.Context, host string) error {
+	if err := l.global.Wait(ctx); err != nil {
+		return err
+	}
+	if err := l.host(host).Wait(ctx); err != nil {
+		return err
+	}
+	if l.cfg.Jitter > 0 {
+		return sleep(ctx, time.Duration(rand.Int63n(int64(l.cfg.Jitter)+1))*time.Millisecond)
+	}
+	return nil
+}
+
+var (
+	limiter     *rateLimiter
+	limiterOnce sync.Once
+)
+
+// waitTurn 按config的限速设置等待发往 host 的请求可以发出
+func waitTurn(ctx context.Context, host string) error {
+	limiterOnce.Do(func() {
+		limiter = newRateLimiter(config.GetRateLimit())
+	})
+	return limiter.Wait(ctx, host)
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"PrintRaptor/config"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := newTokenBucket(20, 1)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := bucket.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 第一个令牌立即可用,之后每个间隔 50ms
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("5个请求耗时 %v, 期望约 200ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bucket = newTokenBucket(1, 1)
+	bucket.Wait(ctx)
+	if err := bucket.Wait(ctx); err == nil {
+		t.Fatal("ctx 取消后应当立即返回错误")
+	}
+}
+
+// TestRateLimiterPerHost 不同主机的令牌桶互不影响
+func TestRateLimiterPerHost(t *testing.T) {
+	l := newRateLimiter(config.RateLimit{PerHost: 10})
+	start := time.Now()
+	for _, host := range []string{"a", "b", "c", "d"} {
+		l.Wait(context.Background(), host)
+	}
+	if time.Since(start) > 50*time.Millisecond {
+		t.Fatal("不同主机不应互相限速")
+	}
+	l.Wait(context.Background(), "a")
+	if time.Since(start) < 90*time.Millisecond {
+		t.Fatal("同一主机的第二个请求应当等待")
+	}
+}
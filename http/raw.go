@@ -0,0 +1,118 @@
+package http
+
+import (
+	"PrintRaptor/config"
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*
+raw 请求
+有些探测需要畸形或者少见的请求: 奇怪的Host头、HTTP/1.0、会被 net/http 规范化掉的路径等,
+这类规则直接写原始请求,这里不做任何处理原样发出去,响应再按 extract 的方式解析
+*/
+
+var noDeadline time.Time
+
+// buildRawRequest 替换 raw 中的占位符: {{Host}} 为 host:port,{{Hostname}} 为不带端口的主机名
+// YAML 里写的块文本只有 \n,整段没有 \r 时把请求头部分的换行转换为 \r\n,请求体保持原样
+func buildRawRequest(raw, host, hostname string) []byte {
+	raw = strings.ReplaceAll(raw, "{{Host}}", host)
+	raw = strings.ReplaceAll(raw, "{{Hostname}}", hostname)
+	if strings.Contains(raw, "\r") {
+		return []byte(raw)
+	}
+	head, body, found := strings.Cut(raw, "\n\n")
+	head = strings.ReplaceAll(head, "\n", "\r\n")
+	if !found {
+		// 没有空行分隔,补上请求头结束标记
+		return []byte(strings.TrimRight(head, "\r\n") + "\r\n\r\n")
+	}
+	return []byte(head + "\r\n\r\n" + body)
+}
+
+// rawMethod 取请求行中的方法,用于正确解析 HEAD 之类没有响应体的响应
+func rawMethod(raw []byte) string {
+	line, _, _ := strings.Cut(string(raw), " ")
+	return strings.ToUpper(strings.TrimSpace(line))
+}
+
+// targetAddr 目标的 host:port
+func (target *Target) targetAddr() string {
+	if target.U.Port() != "" {
+		return target.U.Host
+	}
+	if target.U.Scheme == "https" {
+		return net.JoinHostPort(target.U.Hostname(), "443")
+	}
+	return net.JoinHostPort(target.U.Hostname(), "80")
+}
+
+// hostname 请求使用的主机名,设置了虚拟主机时为虚拟主机
+func (target *Target) hostname() string {
+	if target.Vhost != "" {
+		return target.Vhost
+	}
+	return target.U.Hostname()
+}
+
+// hostHeader Host 头的值,目标地址带端口时虚拟主机也带上端口
+func (target *Target) hostHeader() string {
+	if target.Vhost == "" {
+		return target.U.Host
+	}
+	if port := target.U.Port(); port != "" {
+		return net.JoinHostPort(target.Vhost, port)
+	}
+	return target.Vhost
+}
+
+// serverName TLS 握手时的 SNI,IP 不能作为 SNI
+func (target *Target) serverName() string {
+	if name := target.hostname(); net.ParseIP(name) == nil {
+		return name
+	}
+	return ""
+}
+
+// rawRequest 发送规则中的原始请求,返回的响应读完后需要关闭连接
+func (target *Target) rawRequest(ctx context.Context) (*http.Response, net.Conn, error) {
+	if err := waitTurn(ctx, target.U.Host); err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, config.GetDialTimeOut())
+	defer cancel()
+	conn, err := dialContext(ctx, "tcp", target.targetAddr())
+	if err != nil {
+		return nil, nil, err
+	}
+	if target.U.Scheme == "https" {
+		tlsConfig := &tls.Config{InsecureSkipVerify: true, ServerName: target.serverName()}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn = tlsConn
+	}
+	// 整个读写过程共用一个截止时间,避免慢速响应把扫描卡住
+	_ = conn.SetDeadline(time.Now().Add(config.GetRequestTimeOut()))
+	payload := buildRawRequest(target.CompiledRule.Raw, target.hostHeader(), target.hostname())
+	if _, err := conn.Write(payload); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	req := &http.Request{Method: rawMethod(payload), URL: target.U}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("解析raw请求的响应失败: %w", err)
+	}
+	return resp, conn, nil
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"PrintRaptor/fingerprints"
+	"bufio"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBuildRawRequest(t *testing.T) {
+	raw := "GET /%2e%2e/admin HTTP/1.0\nHost: {{Hostname}}\n\nbody\nline"
+	got := string(buildRawRequest(raw, "10.0.0.1:8080", "10.0.0.1"))
+	want := "GET /%2e%2e/admin HTTP/1.0\r\nHost: 10.0.0.1\r\n\r\nbody\nline"
+	if got != want {
+		t.Fatalf("期望 %q, 实际 %q", want, got)
+	}
+	if got := string(buildRawRequest("OPTIONS * HTTP/1.1\nHost: x", "", "")); got != "OPTIONS * HTTP/1.1\r\nHost: x\r\n\r\n" {
+		t.Errorf("缺少空行时应补全: %q", got)
+	}
+}
+
+// TestRawRequest 起一个TCP服务,确认请求字节原样到达且响应按 extract 解析
+func TestRawRequest(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			select {
+			case received <- line:
+			default:
+			}
+			conn.Write([]byte("HTTP/1.0 200 OK\r\nServer: odd-server\r\n\r\n<title>raw</title>ok"))
+			conn.Close()
+		}
+	}()
+
+	u, _ := url.Parse("http://" + ln.Addr().String())
+	rule := &fingerprints.CompiledRule{RuleConfig: fingerprints.RuleConfig{Raw: "GET /..;/console HTTP/1.0\nHost: bad host\n\n"}}
+	target, _ := NewTarget(u, rule)
+	banner, err := target.Request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := <-received; line != "GET /..;/console HTTP/1.0\r\n" {
+		t.Errorf("请求行被修改: %q", line)
+	}
+	data := banner.ResponseData
+	if data.Title != "raw" || !strings.Contains(data.Headers, "odd-server") {
+		t.Errorf("响应解析错误: %+v", data)
+	}
+}
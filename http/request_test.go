@@ -0,0 +1,94 @@
+package http
+
+import (
+	"PrintRaptor/config"
+	"PrintRaptor/fingerprints"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// loadTestConfig 在临时目录写一份 config.yaml 并加载,只设置请求头和全局 POST 数据,其余配置取默认值
+func loadTestConfig(t *testing.T, content string) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	config.Load()
+}
+
+// TestNewRequest 规则的请求方法、请求头、base64 请求体和 Content-Type 原样到达服务端,
+// 没有写请求体的 isPost 规则沿用全局 POST 数据
+func TestNewRequest(t *testing.T) {
+	// POST 为 base64 编码的 "a=1&b=2"
+	loadTestConfig(t, "ReqHeader:\n  - X-Global: global\n  - X-Probe: global\nPOST: 'YT0xJmI9Mg=='\n")
+
+	type received struct {
+		method, contentType, global, probe string
+		body                               []byte
+	}
+	var mu sync.Mutex
+	requests := make(map[string]received)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests[r.URL.Path] = received{r.Method, r.Header.Get("Content-Type"), r.Header.Get("X-Global"), r.Header.Get("X-Probe"), body}
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	data := `- name: put
+  path: /put
+  method: put
+  headers:
+    X-Probe: rule
+  bodyBase64: AAEC/w==
+  contentType: application/octet-stream
+  expression: body="x"
+- name: legacy
+  path: /legacy
+  isPost: true
+  expression: body="x"
+`
+	rules, err := fingerprints.LoadRulesFromBytes("rules.yaml", []byte(data))
+	if err != nil || len(rules) != 2 {
+		t.Fatalf("规则加载失败: %v", err)
+	}
+	u, _ := url.Parse(server.URL)
+	for i := range rules {
+		target, _ := NewTarget(u, &rules[i])
+		if _, err := target.Request(); err != nil {
+			t.Fatalf("%s: 请求失败: %v", rules[i].Name, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	put := requests["/put"]
+	if put.method != http.MethodPut || put.contentType != "application/octet-stream" {
+		t.Errorf("PUT 规则的方法或 Content-Type 不对: %+v", put)
+	}
+	if string(put.body) != "\x00\x01\x02\xff" {
+		t.Errorf("bodyBase64 应解码后发送, 实际 % x", put.body)
+	}
+	if put.probe != "rule" || put.global != "global" {
+		t.Errorf("规则请求头应覆盖全局同名项并保留其余全局请求头: %+v", put)
+	}
+	legacy := requests["/legacy"]
+	if legacy.method != http.MethodPost || string(legacy.body) != "a=1&b=2" {
+		t.Errorf("isPost 规则应沿用全局 POST 数据, 实际 %s %q", legacy.method, legacy.body)
+	}
+}
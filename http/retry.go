@@ -0,0 +1,99 @@
+package http
+
+import (
+	"PrintRaptor/config"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+)
+
+/*
+失败重试
+只有超时、连接被重置、代理故障以及 429/503 这类短暂性的失败才会重试,按指数退避等待;
+DNS 解析失败、端口拒绝连接、TLS 错误重试也没有意义,直接返回
+*/
+
+// ErrorClass 请求失败的原因分类,记录到结果中用来区分"不是某产品"和"目标不可达"
+type ErrorClass string
+
+const (
+	ErrDNS     ErrorClass = "dns"
+	ErrRefused ErrorClass = "refused"
+	ErrTLS     ErrorClass = "tls"
+	ErrTimeout ErrorClass = "timeout"
+	ErrReset   ErrorClass = "reset"
+	ErrProxy   ErrorClass = "proxy"
+	ErrOther   ErrorClass = "other"
+)
+
+// ClassifyError 判断请求错误的类别,err 为 nil 时返回空字符串
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+	// 代理故障优先判断,里面包着的往往是拒绝连接或超时
+	if config.IsProxyError(err) {
+		return ErrProxy
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrDNS
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrRefused
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrTimeout
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrReset
+	}
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	if errors.As(err, &recordErr) || errors.As(err, &certErr) || errors.As(err, &unknownAuthority) ||
+		strings.Contains(err.Error(), "tls:") {
+		return ErrTLS
+	}
+	return ErrOther
+}
+
+// isRetryable 超时、连接重置、代理故障可以重试
+func isRetryable(err error) bool {
+	switch ClassifyError(err) {
+	case ErrTimeout, ErrReset, ErrProxy:
+		return true
+	}
+	return false
+}
+
+// isRetryableStatus 限流和服务暂时不可用的响应值得再试一次
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
+// withRetry 执行 attempt,它返回 retry 为 true 时按指数退避重试,最多重试 config.GetRetries 次
+// 返回最后一次的错误,ctx 取消后不再重试
+func withRetry(ctx context.Context, attempt func() (retry bool, err error)) error {
+	retries := config.GetRetries()
+	backoff := config.GetRetryBackoff()
+	for i := 0; ; i++ {
+		retry, err := attempt()
+		if !retry || i >= retries {
+			return err
+		}
+		if sleep(ctx, backoff<<i) != nil {
+			return err
+		}
+	}
+}
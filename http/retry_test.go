@@ -0,0 +1,88 @@
+package http
+
+import (
+	"PrintRaptor/fingerprints"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "x.invalid"}}, ErrDNS},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, ErrRefused},
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, ErrReset},
+		{&net.OpError{Op: "proxyconnect", Err: syscall.ECONNREFUSED}, ErrProxy},
+		{context.DeadlineExceeded, ErrTimeout},
+		{errors.New("tls: handshake failure"), ErrTLS},
+		{errors.New("boom"), ErrOther},
+	}
+	for _, c := range cases {
+		if got := ClassifyError(c.err); got != c.want {
+			t.Errorf("ClassifyError(%v) = %s, want %s", c.err, got, c.want)
+		}
+	}
+	if isRetryable(cases[0].err) || !isRetryable(cases[2].err) {
+		t.Error("只有短暂性错误可以重试")
+	}
+}
+
+// TestRequestRetry 503 之后重试拿到正常响应
+func TestRequestRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			return
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("<title>ok</title>"))
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	target, _ := NewTarget(u, &fingerprints.CompiledRule{RuleConfig: fingerprints.RuleConfig{Name: "t", Path: "/"}})
+	banner, err := target.Request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if banner.ResponseData.Title != "ok" || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("期望重试一次后成功, 请求 %d 次, 标题 %q", calls, banner.ResponseData.Title)
+	}
+}
+
+// TestRequestRetryCanceled ctx 取消后不再等待重试退避
+func TestRequestRetryCanceled(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	target, _ := NewTarget(u, &fingerprints.CompiledRule{RuleConfig: fingerprints.RuleConfig{Name: "t", Path: "/"}})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	target.RequestContext(ctx)
+	// 默认退避 500ms 起,两次重试共要等 1.5s
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Fatalf("取消后仍在等待重试, 耗时 %v", elapsed)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("取消后不应再重试, 实际请求 %d 次", n)
+	}
+}
@@ -5,17 +5,17 @@ import (
 	"PrintRaptor/fingerprints"
 	"PrintRaptor/models"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"github.com/twmb/murmur3"
 	"io"
 	"log"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
-	"regexp"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -23,6 +23,7 @@ import (
 type Target struct {
 	U            *url.URL
 	CompiledRule *fingerprints.CompiledRule
+	Vhost        string // 虚拟主机名,不为空时 Host 头和 TLS SNI 都使用它,连接仍然建立到 U
 }
 
 func NewTarget(targetUrl *url.URL, rule *fingerprints.CompiledRule) (*Target, error) {
@@ -479,13 +480,24 @@ func Encode(buf []byte) string {
 	return mmh3Hash32(standBase64(buf))
 }
 
-func post(url string) (*http.Request, error) {
-	data, err := config.GetPostData()
-	if err != nil {
-		log.Println(err)
-		data = []byte{} //置空
+// newRequest 按规则构造请求: 方法和请求体取规则自己的配置,
+// 没有写请求体的POST/PUT沿用config中全局的POST数据;请求头先取全局配置再用规则中的覆盖
+func newRequest(url string, rule *fingerprints.RuleConfig) (*http.Request, error) {
+	method := rule.HTTPMethod(rule.IsPost)
+	body, ok := rule.RequestBody()
+	if !ok && (method == http.MethodPost || method == http.MethodPut) {
+		data, err := config.GetPostData()
+		if err != nil {
+			log.Println(err)
+			data = []byte{} //置空
+		}
+		body = data
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
 	}
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	req, err := http.NewRequest(method, url, reader)
 	if err != nil {
 		return nil, err
 	}
@@ -493,171 +505,160 @@ func post(url string) (*http.Request, error) {
 	if err != nil {
 		log.Printf("config 请求头解析错误 %v", err)
 		// 自清理
-		for k := range headers {
-			headers.Del(k)
-		}
+		headers = http.Header{}
 	}
 	//加一组新的ua
 	headers.Set("User-Agent", RandomUserAgent())
-	req.Header = headers
-	return req, nil
-}
-
-func get(url string) (*http.Request, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	headers, err := config.GetHeaders()
-	if err != nil {
-		log.Printf("config 请求头解析错误 %v", err)
-		// 自清理
-		for k := range headers {
-			headers.Del(k)
-		}
+	if rule.ContentType != "" {
+		headers.Set("Content-Type", rule.ContentType)
+	}
+	for k, v := range rule.Headers {
+		headers.Set(k, v)
 	}
-	//加一组新的ua
-	headers.Set("User-Agent", RandomUserAgent())
 	req.Header = headers
+	// Host 头需要单独设置到 req.Host 上才会生效
+	if host := headers.Get("Host"); host != "" {
+		req.Host = host
+	}
 	return req, nil
 }
-func headerToString(header http.Header) string {
-	var sb strings.Builder
-	for key, values := range header {
-		for _, value := range values {
-			sb.WriteString(key)
-			sb.WriteString(": ")
-			sb.WriteString(value)
-			sb.WriteString("\r\n")
-		}
+
+// setVhost 设置了虚拟主机时改写 Host 头,规则或配置中显式写了 Host 头的优先
+func (target *Target) setVhost(req *http.Request) {
+	if target.Vhost != "" && req.Header.Get("Host") == "" {
+		req.Host = target.hostHeader()
 	}
-	return sb.String()
 }
 
 // GetIconHash 接收一个resp.Body
-func (target *Target) getIconHash() (string, error) {
-	resp, err := http.Get(target.U.Scheme + "://" + target.U.Host + "/favicon.ico")
+func (target *Target) getIconHash(ctx context.Context) (string, error) {
+	if err := waitTurn(ctx, target.U.Host); err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, config.GetRequestTimeOut())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.U.Scheme+"://"+target.U.Host+"/favicon.ico", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", RandomUserAgent())
+	target.setVhost(req)
+	resp, err := do(req)
 	if err != nil {
 		return "", err
 	}
-	body, err := io.ReadAll(resp.Body)
-	return mmh3Hash32(standBase64(body)), err
+	defer resp.Body.Close()
+	body, truncated := readBody(resp.Body, config.GetMaxBodySize(), config.GetBodyTimeOut(), cancel)
+	if truncated {
+		return "", fmt.Errorf("favicon 读取不完整")
+	}
+	return mmh3Hash32(standBase64(body)), nil
 }
 
-// 根据target 解析出指纹数据
-func (target *Target) extract(response *http.Response) (*fingerprints.ResponseData, error) {
-	responseData := &fingerprints.ResponseData{} //记得初始化指针啊
-	//var responseData *fingerprints.ResponseData //这样是错误的,指针没有初始化,后面不能直接赋值
-	hash, err := target.getIconHash()
+// 根据target 解析出指纹数据,abort 用于读取响应体超时时中断连接
+func (target *Target) extract(ctx context.Context, response *http.Response, abort func()) (*fingerprints.ResponseData, error) {
+	// 先读完响应体,连接归还后 favicon 请求可以复用它
+	body, truncated := readBody(response.Body, config.GetMaxBodySize(), config.GetBodyTimeOut(), abort)
+	hash, err := target.getIconHash(ctx)
 	if err != nil {
 		hash = ""
 	}
+	responseData := fingerprints.NewResponseData(target.U.Host, response.Header, body)
 	responseData.Hash = hash
-	body, _ := io.ReadAll(response.Body)
-	responseData.Body = string(body)
-	if responseData.Body == "" {
-		responseData.Host = target.U.Host
-		responseData.Headers = headerToString(response.Header)
-		return responseData, nil
+	responseData.Truncated = truncated
+	responseData.Status = response.StatusCode
+	if response.Request != nil {
+		responseData.URL = response.Request.URL.String()
 	}
-	responseData.Host = target.U.Host
-	responseData.Headers = headerToString(response.Header)
-	responseData.ICP = getICP(responseData.Body)
-	responseData.BodyLength = len(body)
-	responseData.Title, _ = GetTitle(responseData.Body)
-
 	return responseData, nil
 }
 
-// GetTitle 提取 HTML 中的 <title> 内容
-func GetTitle(body string) (string, error) {
-	// 正则提取 <title> 标签内容（忽略大小写）
-	re := regexp.MustCompile(`(?i)<title[^>]*>(.*?)</title>`)
-	match := re.FindStringSubmatch(body)
-	if len(match) > 1 {
-		// 清理前后空白
-		return strings.TrimSpace(match[1]), nil
-	}
-	return "", nil // 没有 <title> 标签
-}
-
-var (
-	provinces = []string{
-		"京", "津", "冀", "晋", "蒙", "辽", "吉", "黑",
-		"沪", "苏", "浙", "皖", "闽", "赣", "鲁", "豫",
-		"湘", "粤", "桂", "琼", "川", "蜀", "贵", "黔",
-		"云", "滇", "渝", "藏", "陕", "秦", "甘", "陇",
-		"青", "宁", "新", "港", "澳", "台", "鄂",
-	}
-	provincesString = strings.Join(provinces, "|")
-	icpRegx         = regexp.MustCompile(`(?:` + provincesString + `)ICP备\s*\d+号(?:-\d+)?`)
-)
-
-func getICP(body string) string {
-	if icpRegx.MatchString(body) == true {
-		return icpRegx.FindString(body)
-	}
-	return ""
-}
-
 // Request 后的结果拿去给做指纹匹配
 // 组合target和responseData得到一个banner
 // 然后用于指纹匹配,交付最终的结果处理
 func (target *Target) Request() (*models.Banner, error) {
+	return target.RequestContext(context.Background())
+}
+
+// RequestContext 同 Request,ctx 取消后中断限速等待、重试退避和进行中的请求
+func (target *Target) RequestContext(ctx context.Context) (*models.Banner, error) {
 	banner := &models.Banner{}
 	banner.CompiledRule = target.CompiledRule
-	if target.CompiledRule.IsPost {
-		//	post
-		req, err := post(target.U.Scheme + "://" + target.U.Host + target.CompiledRule.Path)
-		if err != nil {
-			return nil, err
-		}
-		transport, err := config.GetProxy()
-		if err != nil {
-			return nil, err
+	if target.CompiledRule.Raw != "" {
+		return target.requestRaw(ctx, banner)
+	}
+	var resp *http.Response
+	cancel := func() {}
+	err := withRetry(ctx, func() (bool, error) {
+		// 上一次的响应(429/503)丢弃掉再重试
+		if resp != nil {
+			resp.Body.Close()
+			resp = nil
 		}
-		client := &http.Client{Transport: transport}
-		resp, err := client.Do(req)
-		//处理返回body为空的时候
+		cancel()
+		req, err := newRequest(target.U.Scheme+"://"+target.U.Host+target.CompiledRule.Path, &target.CompiledRule.RuleConfig)
 		if err != nil {
-			log.Printf("请求错误 %v\n", err)
-			if banner.ResponseData == nil {
-				banner.ResponseData = &fingerprints.ResponseData{}
-			}
-			banner.ResponseData.Host = target.U.Host
-			return banner, err
+			return false, err
 		}
-		defer resp.Body.Close()
-		responseData, err := target.extract(resp)
-		if err != nil {
-			return nil, err
+		target.setVhost(req)
+		// 限速等待不计入请求超时
+		if err := waitTurn(ctx, target.U.Host); err != nil {
+			return false, err
 		}
-		banner.ResponseData = responseData
-		return banner, nil
-	} else {
-		//get
-		req, err := get(target.U.Scheme + "://" + target.U.Host + target.CompiledRule.Path)
+		// 总超时覆盖建连、等待响应头和读取响应体
+		var reqCtx context.Context
+		reqCtx, cancel = context.WithTimeout(ctx, config.GetRequestTimeOut())
+		resp, err = do(req.WithContext(reqCtx))
 		if err != nil {
-			return nil, err
+			return isRetryable(err), err
 		}
-		transport, err := config.GetProxy()
-		if err != nil {
-			return nil, err
+		return isRetryableStatus(resp.StatusCode), nil
+	})
+	defer func() { cancel() }()
+	//处理返回body为空的时候
+	if err != nil {
+		log.Printf("请求错误 %v\n", err)
+		if banner.ResponseData == nil {
+			banner.ResponseData = &fingerprints.ResponseData{}
 		}
-		client := &http.Client{Transport: transport}
-		resp, err := client.Do(req)
-		//处理返回body为空的时候
-		if err != nil {
-			log.Printf("请求错误 %v\n", err)
-			if banner.ResponseData == nil {
-				banner.ResponseData = &fingerprints.ResponseData{}
-			}
-			banner.ResponseData.Host = target.U.Host
-			return banner, err
+		banner.ResponseData.Host = target.U.Host
+		return banner, err
+	}
+	defer resp.Body.Close()
+	responseData, err := target.extract(ctx, resp, cancel)
+	if err != nil {
+		return nil, err
+	}
+	banner.ResponseData = responseData
+	return banner, nil
+}
+
+// requestRaw raw 模式的请求,响应同样交给 extract 解析
+func (target *Target) requestRaw(ctx context.Context, banner *models.Banner) (*models.Banner, error) {
+	var resp *http.Response
+	var conn net.Conn
+	err := withRetry(ctx, func() (bool, error) {
+		if conn != nil {
+			conn.Close()
 		}
-		defer resp.Body.Close()
-		responseData, err := target.extract(resp)
+		var err error
+		resp, conn, err = target.rawRequest(ctx)
 		if err != nil {
-			return nil, err
+			return isRetryable(err), err
 		}
-		banner.ResponseData = responseData
-		return banner, nil
+		return isRetryableStatus(resp.StatusCode), nil
+	})
+	if err != nil {
+		log.Printf("请求错误 %v\n", err)
+		banner.ResponseData = &fingerprints.ResponseData{Host: target.U.Host}
+		return banner, err
+	}
+	defer conn.Close()
+	defer resp.Body.Close()
+	responseData, err := target.extract(ctx, resp, func() { conn.SetReadDeadline(time.Now()) })
+	if err != nil {
+		return nil, err
 	}
+	banner.ResponseData = responseData
+	return banner, nil
 }
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"PrintRaptor/config"
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+/*
+虚拟主机
+只有IP的目标按 Host 头(https 还有 SNI)返回不同的应用,需要带上候选的主机名分别请求。
+候选主机名除了目标文件中写的,还可以从目标的 TLS 证书(SAN、CN)和 IP 的反向解析中获取
+*/
+
+// sniClients SNI -> 共用连接池配置、只改了 ServerName 的 client
+var sniClients sync.Map
+
+// clientFor https 请求的 Host 与连接的地址不同时,使用以 Host 作为 SNI 的 client
+func clientFor(req *http.Request) (*http.Client, error) {
+	client, err := getClient()
+	if err != nil || req.URL.Scheme != "https" || req.Host == "" {
+		return client, err
+	}
+	name := req.Host
+	if host, _, err := net.SplitHostPort(name); err == nil {
+		name = host
+	}
+	if name == req.URL.Hostname() || net.ParseIP(name) != nil {
+		return client, nil
+	}
+	if c, ok := sniClients.Load(name); ok {
+		return c.(*http.Client), nil
+	}
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.ServerName = name
+	c, _ := sniClients.LoadOrStore(name, &http.Client{Transport: transport})
+	return c.(*http.Client), nil
+}
+
+// CertNames 目标 TLS 证书中的域名,通配符域名无法直接请求,跳过
+func CertNames(ctx context.Context, u *url.URL) ([]string, error) {
+	target := &Target{U: u}
+	if err := waitTurn(ctx, u.Host); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, config.GetRequestTimeOut())
+	defer cancel()
+	conn, err := dialContext(ctx, "tcp", target.targetAddr())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true, ServerName: target.serverName()})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, nil
+	}
+	leaf := certs[0]
+	var names []string
+	for _, name := range append([]string{leaf.Subject.CommonName}, leaf.DNSNames...) {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || strings.Contains(name, "*") || net.ParseIP(name) != nil || contains(names, name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// ReverseDNS IP 反向解析得到的域名,不是 IP 或解析失败时为空
+func ReverseDNS(host string) []string {
+	if net.ParseIP(host) == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), config.GetDialTimeOut())
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, addr := range addrs {
+		if name := strings.ToLower(strings.TrimSuffix(addr, ".")); name != "" && !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
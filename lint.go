@@ -0,0 +1,52 @@
+package main
+
+import (
+	"PrintRaptor/config"
+	"PrintRaptor/fingerprints"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runLint 校验指纹文件,存在问题时返回非零退出码,方便在指纹仓库里做门禁
+// 用法: PrintRaptor lint [-strict] [file|dir|glob ...],不指定时使用config中的FingerFilePath
+func runLint(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	strict := fs.Bool("strict", false, "warning 也视为失败")
+	_ = fs.Parse(args)
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		config.Load()
+		fingerFilePaths, err := config.GetFingerFilePaths()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "初始化指纹文件路径失败: %v\n", err)
+			return 2
+		}
+		if len(fingerFilePaths) == 0 {
+			fmt.Fprintln(os.Stderr, "未指定指纹文件,FingerFilePath配置项也为空")
+			return 2
+		}
+		paths = fingerFilePaths
+	}
+
+	all, fileCount, err := fingerprints.LintFiles(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	errCount, warnCount := 0, 0
+	for _, issue := range all {
+		fmt.Println(issue.String())
+		if issue.Level == fingerprints.LintError {
+			errCount++
+		} else {
+			warnCount++
+		}
+	}
+	fmt.Printf("检查了 %d 个文件: %d 个错误, %d 个警告\n", fileCount, errCount, warnCount)
+	if fingerprints.HasLintErrors(all, *strict) {
+		return 1
+	}
+	return 0
+}
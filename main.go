@@ -2,14 +2,29 @@ package main
 
 import (
 	"PrintRaptor/config"
+	"PrintRaptor/engine"
 	"PrintRaptor/fingerprints"
-	"PrintRaptor/http"
 	"PrintRaptor/models"
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
+	// 子命令,不打印logo,输出直接给脚本/CI使用
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lint":
+			os.Exit(runLint(os.Args[2:]))
+		case "test":
+			os.Exit(runRuleTests(os.Args[2:]))
+		}
+	}
+	opts := parseScanOptions(os.Args[1:])
 	logo := `  ____  ____  ___ _   _ _____ ____     _    ____ _____ ___  ____   
  |  _ \|  _ \|_ _| \ | |_   _|  _ \   / \  |  _ |_   _/ _ \|  _ \  
  | |_) | |_) || ||  \| | | | | |_) | / _ \ | |_) || || | | | |_) | 
@@ -19,72 +34,132 @@ func main() {
                                           𝓑𝓨 : 𝓔𝓿𝓲𝓭𝓮𝓷`
 	fmt.Println(logo)
 	config.Load()
-	if config.IsFastMode() {
-		//快速模式
-		fingerFilePath, err := config.GetFingerFilePath()
-		if err != nil {
-			log.Fatalf("初始化指纹文件路径失败: %v", err)
-		}
-		rules, err := fingerprints.LoadRulesFromFile(fingerFilePath)
-		fmt.Printf("Loading rules from %s ,Loaded %d 条\n", fingerFilePath, len(rules))
-		if err != nil {
+	if err := applyRuleFilter(opts); err != nil {
+		log.Fatal(err)
+	}
+	rules, err := loadRules()
+	if err != nil {
+		log.Fatal(err)
+	}
+	scanner := newScanner(rules)
+	if opts.crawl > 0 {
+		scanner.CrawlDepth = opts.crawl
+	}
+	cacheDir := opts.cacheDir
+	if cacheDir == "" {
+		cacheDir = config.GetCacheDir()
+	}
+	if opts.offline {
+		os.Exit(runOffline(scanner, cacheDir))
+	}
+	if len(opts.inputs) > 0 {
+		os.Exit(runImport(scanner, opts.inputs))
+	}
+	if opts.listen != "" {
+		os.Exit(runPassive(scanner, opts.listen))
+	}
+	if cacheDir != "" {
+		if scanner.Cache, err = models.OpenResponseCache(cacheDir); err != nil {
 			log.Fatal(err)
 		}
-		targetFilePath, err := config.GetTargetFilePath()
-		if err != nil {
-			log.Fatalf("初始化目标文件失败: %v", err)
+	}
+	targetFilePath, err := config.GetTargetFilePath()
+	if err != nil {
+		log.Fatalf("初始化目标文件失败: %v", err)
+	}
+	targets, err := models.LoadTargets(targetFilePath)
+	if err != nil {
+		log.Fatalf("Failed to load targets from file: %v", err)
+	}
+	stateFile := opts.stateFile
+	if stateFile == "" {
+		stateFile = config.GetStateFile()
+	}
+	state, err := models.OpenState(stateFile, opts.resume)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer state.Close()
+	// 规则组: 规则集和扫描模式(含爬取深度),二者不变时已完成的目标才可以跳过
+	ruleSet := fingerprints.RuleSetID(rules)
+	if scanner.FastMode {
+		ruleSet += "-fast"
+	} else if scanner.CrawlDepth > 0 {
+		ruleSet += fmt.Sprintf("-crawl%d", scanner.CrawlDepth)
+	}
+	if opts.resume {
+		log.Printf("从 %s 继续扫描,已完成 %d 个目标", stateFile, state.Len())
+	}
+
+	// 第一次 Ctrl+C 停止发出新请求,输出已有的部分结果后退出;再按一次直接结束
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			break
 		}
-		targetsU, err := models.LoadFromFile(targetFilePath)
-		if err != nil {
-			log.Fatalf("Failed to load targets from file: %v", err)
+		target.Vhosts = append(target.Vhosts, opts.vhosts...)
+		// 目标自身已经扫完时沿用当时记录的候选主机名,不再重新读取证书和反向解析
+		vhosts, ok := state.Vhosts(models.TargetKey(target.URL, ""), ruleSet)
+		if !ok {
+			vhosts = scanner.Vhosts(ctx, target)
 		}
-		//过一遍responseData即可,若第一次就为空那么直接退出
-		for _, targetUrl := range targetsU {
-			// 对于每个targetUrl只需要Request一次,然后更换banner.CompiledRule 就去匹配即可
-			target, err := http.NewTarget(targetUrl, &rules[0])
-			if err != nil {
-				log.Printf("Error creating target for %s: %v", targetUrl, err)
-				continue
+		// 先按目标自身的主机名扫描,再依次带上每个虚拟主机名,每个组合单独输出和记录进度
+		for _, vhost := range append([]string{""}, vhosts...) {
+			if ctx.Err() != nil {
+				break
 			}
-			banner, err := target.Request()
-			if err != nil || banner == nil {
-				log.Printf("Request failed for %s: %v", targetUrl, err)
+			key := models.TargetKey(target.URL, vhost)
+			if result, ok := state.Done(key, ruleSet); ok {
+				result.Print()
 				continue
 			}
-			for _, rule := range rules {
-				banner.CompiledRule = &rule
-				// 这里可以做匹配或打印
-				banner.Print()
+			// 上次扫到一半被中断的目标,已经拿到的响应直接复用
+			resumed, _ := state.Partial(key, ruleSet)
+			// 同一主机的命中聚合后统一输出
+			result, responses := scanner.ResumeVhost(ctx, target.URL, vhost, resumed)
+			result.Print()
+			if result.Interrupted {
+				err = state.SavePartial(key, ruleSet, result, responses)
+			} else {
+				var candidates []string
+				if vhost == "" {
+					candidates = vhosts
+				}
+				err = state.Save(key, ruleSet, result, candidates...)
+			}
+			if err != nil {
+				log.Println(err)
 			}
 		}
-	} else {
-		fingerFilePath, err := config.GetFingerFilePath()
-		if err != nil {
-			log.Fatalf("初始化指纹文件路径失败: %v", err)
-		}
-		rules, err := fingerprints.LoadRulesFromFile(fingerFilePath)
-		fmt.Printf("🔍 Loading rules from %s ,Loaded %d 条\n", fingerFilePath, len(rules))
-		if err != nil {
+	}
+	if ctx.Err() != nil {
+		log.Printf("扫描已中断,进度保存在 %s,使用 -resume 继续", stateFile)
+	}
+}
+
+// newScanner 按config构造扫描器
+// 快速模式只请求一次根路径,所有规则复用这一份响应;精准模式会按依赖关系请求特殊路径
+func newScanner(rules []fingerprints.CompiledRule) *engine.Scanner {
+	scanner := engine.NewScanner(rules, config.IsFastMode())
+	scanner.Soft404 = config.Soft404Check()
+	crawl := config.GetCrawl()
+	scanner.CrawlDepth, scanner.CrawlPages = crawl.Depth, crawl.MaxPages
+	js := config.GetJsAnalysis()
+	scanner.JSAnalysis, scanner.MaxScripts = js.Enable, js.MaxScripts
+	vhost := config.GetVhost()
+	scanner.VhostFromCert, scanner.VhostReverseDNS, scanner.MaxVhosts = vhost.FromCert, vhost.ReverseDNS, vhost.Max
+	if waf := config.GetWaf(); waf.Detect {
+		var err error
+		if scanner.Waf, err = fingerprints.LoadWafRules(); err != nil {
 			log.Fatal(err)
 		}
-		targetFilePath, err := config.GetTargetFilePath()
-		if err != nil {
-			log.Fatalf("初始化目标文件失败: %v", err)
-		}
-		targetsU, err := models.LoadFromFile(targetFilePath)
-		if err != nil {
-			log.Fatalf("Failed to load targets from file: %v", err)
-		}
-		for _, targetUrl := range targetsU {
-			for _, rule := range rules {
-				target, err := http.NewTarget(targetUrl, &rule)
-				if err != nil {
-					log.Printf("Error creating target for %s with rule %s: %v", targetUrl, rule.Name, err)
-					continue
-				}
-				banner, err := target.Request()
-				banner.Print()
-			}
-		}
+		scanner.OnBlock = waf.OnBlock
+		scanner.BlockBackoff = time.Duration(waf.Backoff) * time.Millisecond
 	}
+	return scanner
 }
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"PrintRaptor/fingerprints"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+响应缓存
+每个目标一个JSON文件,保存扫描时拿到的所有响应(按请求区分),
+改了指纹之后可以离线对缓存重新匹配,不用再去请求目标
+*/
+
+// CachedTarget 一个目标的缓存文件内容
+type CachedTarget struct {
+	Target    string                                `json:"target"`
+	Responses map[string]*fingerprints.ResponseData `json:"responses"` // 请求 -> 响应
+}
+
+// ResponseCache 缓存目录
+type ResponseCache struct {
+	dir string
+}
+
+// OpenResponseCache 打开缓存目录,不存在时创建
+func OpenResponseCache(dir string) (*ResponseCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	return &ResponseCache{dir: dir}, nil
+}
+
+func (c *ResponseCache) file(target string) string {
+	sum := sha1.Sum([]byte(target))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// Load 读取目标的缓存,没有缓存时返回空表
+func (c *ResponseCache) Load(target string) (map[string]*fingerprints.ResponseData, error) {
+	data, err := os.ReadFile(c.file(target))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]*fingerprints.ResponseData{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取缓存失败: %w", err)
+	}
+	var cached CachedTarget
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, fmt.Errorf("缓存文件 %s 损坏: %w", c.file(target), err)
+	}
+	if cached.Responses == nil {
+		cached.Responses = map[string]*fingerprints.ResponseData{}
+	}
+	return cached.Responses, nil
+}
+
+// Save 覆盖写入目标的缓存,先写临时文件再改名,中断时不会留下写了一半的文件
+func (c *ResponseCache) Save(target string, responses map[string]*fingerprints.ResponseData) error {
+	data, err := json.Marshal(CachedTarget{Target: target, Responses: responses})
+	if err != nil {
+		return err
+	}
+	path := c.file(target)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("写入缓存失败: %w", err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Targets 缓存中的所有目标,按字母序
+func (c *ResponseCache) Targets() ([]string, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取缓存目录失败: %w", err)
+	}
+	var targets []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(c.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var cached struct {
+			Target string `json:"target"`
+		}
+		if json.Unmarshal(data, &cached) == nil && cached.Target != "" {
+			targets = append(targets, cached.Target)
+		}
+	}
+	sort.Strings(targets)
+	return targets, nil
+}
@@ -0,0 +1,270 @@
+package models
+
+import (
+	"PrintRaptor/fingerprints"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+按主机聚合命中结果
+同一产品(规则名)的多次命中合并成一条,根据 rank 和独立命中的规则数算出置信度,
+互相冲突的产品同时命中时打上标记
+*/
+
+// DefaultRank 规则没有写 rank 时使用的默认值,与内置指纹库保持一致
+const DefaultRank = fingerprints.DefaultRank
+
+// MaxRank rank 的上限,rank/MaxRank 即单条规则命中时的置信度
+const MaxRank = 200
+
+// Hit 一次规则命中
+// 结果会写入断点续扫的状态文件,Rule 不参与序列化,输出用到的字段单独保存
+type Hit struct {
+	Rule       *fingerprints.CompiledRule `json:"-"`
+	URL        string                     `json:"url"` // 命中时请求的地址
+	Expression string                     `json:"expression"`
+}
+
+// Product 同一产品的所有命中
+type Product struct {
+	Name       string   `json:"name"`
+	Tags       []string `json:"tags,omitempty"`
+	Hits       []Hit    `json:"hits,omitempty"`
+	Confidence float64  `json:"confidence"`          // 0~1
+	Conflicts  []string `json:"conflicts,omitempty"` // 同时命中的冲突产品
+	ImpliedBy  []string `json:"impliedBy,omitempty"` // 由这些产品推断得出(规则的 implies)
+}
+
+// RequestError 一次重试后仍然失败的请求
+type RequestError struct {
+	URL   string `json:"url"`
+	Class string `json:"class"` // 失败原因分类: dns、refused、tls、timeout、reset、proxy、other
+	Err   string `json:"error"`
+}
+
+// HostResult 一个主机的聚合结果
+type HostResult struct {
+	Host        string                `json:"host"`
+	Vhost       string                `json:"vhost,omitempty"` // 请求时使用的虚拟主机名(Host 头和 SNI)
+	Title       string                `json:"title"`
+	BodyLength  int                   `json:"bodyLength"`
+	Hash        string                `json:"hash"`
+	Products    []*Product            `json:"products"`
+	Errors      []RequestError        `json:"errors,omitempty"`
+	Unreachable bool                  `json:"unreachable,omitempty"` // 首个请求就失败了,没有识别结果不代表"不是某产品"
+	Wafs        []fingerprints.WafHit `json:"wafs,omitempty"`        // 识别出的WAF/CDN,同名只保留一条
+	Blocked     bool                  `json:"blocked,omitempty"`     // 遇到过WAF拦截页,部分路径的结果可能缺失
+	Wildcard    int                   `json:"wildcard,omitempty"`    // 与随机路径的响应无法区分而被忽略的特殊路径响应数
+	Interrupted bool                  `json:"interrupted,omitempty"` // 扫描被中断,结果不完整
+	byName      map[string]*Product
+}
+
+func NewHostResult(host string) *HostResult {
+	return &HostResult{Host: host, byName: make(map[string]*Product)}
+}
+
+// Add 对banner求值,命中则并入结果,返回是否命中
+func (r *HostResult) Add(banner *Banner) bool {
+	if banner == nil || banner.ResponseData == nil || banner.CompiledRule == nil || banner.CompiledRule.AST == nil {
+		return false
+	}
+	if !banner.CompiledRule.AST.Eval(banner.ResponseData) {
+		return false
+	}
+	r.AddHit(banner.CompiledRule, banner.ResponseData.URL)
+	return true
+}
+
+// AddHit 直接记录一次命中
+func (r *HostResult) AddHit(rule *fingerprints.CompiledRule, url string) {
+	product := r.product(rule.Name)
+	for _, tag := range strings.Split(rule.Tag, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" && !contains(product.Tags, tag) {
+			product.Tags = append(product.Tags, tag)
+		}
+	}
+	// 同一条规则(路径+表达式相同)重复命中只算一次
+	for _, hit := range product.Hits {
+		if hit.Rule.Path == rule.Path && hit.Rule.Expression == rule.Expression {
+			return
+		}
+	}
+	product.Hits = append(product.Hits, Hit{Rule: rule, URL: url, Expression: rule.Expression})
+}
+
+func (r *HostResult) product(name string) *Product {
+	key := strings.ToLower(name)
+	product, ok := r.byName[key]
+	if !ok {
+		product = &Product{Name: name}
+		r.byName[key] = product
+		r.Products = append(r.Products, product)
+	}
+	return product
+}
+
+// AddError 记录一次失败的请求
+func (r *HostResult) AddError(url, class string, err error) {
+	r.Errors = append(r.Errors, RequestError{URL: url, Class: class, Err: err.Error()})
+}
+
+// AddWaf 记录识别出的WAF/CDN
+func (r *HostResult) AddWaf(hit fingerprints.WafHit) {
+	if hit.Block {
+		r.Blocked = true
+	}
+	for i := range r.Wafs {
+		if r.Wafs[i].Name == hit.Name {
+			r.Wafs[i].Block = r.Wafs[i].Block || hit.Block
+			return
+		}
+	}
+	r.Wafs = append(r.Wafs, hit)
+}
+
+// Has 产品是否已识别(直接命中或推断得出),不区分大小写
+func (r *HostResult) Has(name string) bool {
+	_, ok := r.byName[strings.ToLower(name)]
+	return ok
+}
+
+// Imply 记录由 by 推断出的产品 name,返回是否为新识别的产品
+func (r *HostResult) Imply(name, by string) bool {
+	isNew := !r.Has(name)
+	product := r.product(name)
+	if !contains(product.ImpliedBy, by) {
+		product.ImpliedBy = append(product.ImpliedBy, by)
+	}
+	return isNew
+}
+
+// SetInfo 主机基本信息(标题、长度、hash)只取根路径的响应,其他路径的响应不能代表主机
+func (r *HostResult) SetInfo(root *fingerprints.ResponseData) {
+	r.Title = root.Title
+	r.BodyLength = root.BodyLength
+	r.Hash = root.Hash
+}
+
+// Finish 计算置信度、标记冲突并按置信度排序,输出前调用一次
+func (r *HostResult) Finish() {
+	for _, product := range r.Products {
+		product.Confidence = confidence(product.Hits)
+		product.Conflicts = nil
+	}
+	// 推断出的产品取推断来源中最高的置信度,推断总是在来源之后加入,按顺序算一遍即可
+	for _, product := range r.Products {
+		for _, by := range product.ImpliedBy {
+			if from, ok := r.byName[strings.ToLower(by)]; ok && from.Confidence > product.Confidence {
+				product.Confidence = from.Confidence
+			}
+		}
+	}
+	for _, product := range r.Products {
+		for _, hit := range product.Hits {
+			for _, name := range hit.Rule.Conflicts {
+				other, ok := r.byName[strings.ToLower(name)]
+				if !ok || other == product {
+					continue
+				}
+				markConflict(product, other.Name)
+				markConflict(other, product.Name)
+			}
+		}
+	}
+	sort.SliceStable(r.Products, func(i, j int) bool {
+		return r.Products[i].Confidence > r.Products[j].Confidence
+	})
+}
+
+// confidence 每条独立命中的规则看作一次独立证据: 1 - ∏(1 - rank/MaxRank)
+func confidence(hits []Hit) float64 {
+	miss := 1.0
+	for _, hit := range hits {
+		rank := hit.Rule.EffectiveRank()
+		if rank > MaxRank {
+			rank = MaxRank
+		}
+		miss *= 1 - float64(rank)/MaxRank
+	}
+	return 1 - miss
+}
+
+func markConflict(product *Product, name string) {
+	if !contains(product.Conflicts, name) {
+		product.Conflicts = append(product.Conflicts, name)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// name 输出用的主机名,带上虚拟主机
+func (r *HostResult) name() string {
+	if r.Vhost == "" {
+		return r.Host
+	}
+	return r.Host + " (Host: " + r.Vhost + ")"
+}
+
+// Print 输出一个主机的聚合结果,没有命中时不输出,目标不可达时只输出失败原因
+func (r *HostResult) Print() {
+	if r == nil {
+		return
+	}
+	if r.Unreachable {
+		fmt.Printf("主机信息: %s 无法访问", r.name())
+		if len(r.Errors) > 0 {
+			fmt.Printf(" (%s: %s)", r.Errors[0].Class, r.Errors[0].Err)
+		}
+		fmt.Println()
+		return
+	}
+	if len(r.Products) == 0 && !r.Blocked {
+		return
+	}
+	fmt.Println("主机信息: " + r.name())
+	fmt.Println("标题信息: " + r.Title)
+	fmt.Println("数据包长度:", r.BodyLength)
+	fmt.Println("Icon Hash: " + r.Hash)
+	if len(r.Wafs) > 0 {
+		names := make([]string, 0, len(r.Wafs))
+		for _, waf := range r.Wafs {
+			names = append(names, waf.Name+"("+waf.Kind+")")
+		}
+		fmt.Println("WAF/CDN: " + strings.Join(names, ", "))
+	}
+	if r.Blocked {
+		fmt.Println("⚠️ 请求被WAF拦截,识别结果可能不完整")
+	}
+	if r.Interrupted {
+		fmt.Println("⚠️ 扫描被中断,识别结果不完整")
+	}
+	if r.Wildcard > 0 {
+		fmt.Printf("⚠️ 任意路径都返回相同页面,已忽略 %d 个与之无法区分的特殊路径响应\n", r.Wildcard)
+	}
+	fmt.Println("识别结果: ")
+	for _, product := range r.Products {
+		line := fmt.Sprintf("  [%3.0f%%] %s", product.Confidence*100, product.Name)
+		if len(product.Tags) > 0 {
+			line += " 标签: " + strings.Join(product.Tags, ",")
+		}
+		if len(product.ImpliedBy) > 0 {
+			line += " (推断自 " + strings.Join(product.ImpliedBy, ",") + ")"
+		}
+		if len(product.Conflicts) > 0 {
+			line += " ⚠️ 与 " + strings.Join(product.Conflicts, ",") + " 冲突"
+		}
+		fmt.Println(line)
+		for _, hit := range product.Hits {
+			fmt.Printf("      命中规则: %s  %s\n", hit.URL, hit.Expression)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"PrintRaptor/fingerprints"
+	"math"
+	"testing"
+)
+
+func TestHostResult(t *testing.T) {
+	rule := func(name, path, expr string, rank int, conflicts ...string) *fingerprints.CompiledRule {
+		return &fingerprints.CompiledRule{RuleConfig: fingerprints.RuleConfig{
+			Name: name, Path: path, Expression: expr, Rank: rank, Conflicts: conflicts,
+		}}
+	}
+	result := NewHostResult("127.0.0.1:8080")
+	tomcat := rule("tomcat", "/", `header="Coyote"`, 100)
+	result.AddHit(tomcat, "http://127.0.0.1:8080/")
+	result.AddHit(tomcat, "http://127.0.0.1:8080/") // 重复命中
+	result.AddHit(rule("Tomcat", "/manager/html", `body="Tomcat Manager"`, 100), "http://127.0.0.1:8080/manager/html")
+	result.AddHit(rule("jetty", "/", `header="Jetty"`, 0, "tomcat"), "http://127.0.0.1:8080/")
+	result.Finish()
+
+	if len(result.Products) != 2 {
+		t.Fatalf("期望聚合为 2 个产品, 实际 %d", len(result.Products))
+	}
+	top := result.Products[0]
+	if top.Name != "tomcat" || len(top.Hits) != 2 {
+		t.Fatalf("tomcat 聚合错误: %+v", top)
+	}
+	// 两条 rank 100 的独立规则: 1 - 0.5*0.5
+	if math.Abs(top.Confidence-0.75) > 1e-9 {
+		t.Errorf("置信度计算错误: %v", top.Confidence)
+	}
+	jetty := result.Products[1]
+	if math.Abs(jetty.Confidence-0.65) > 1e-9 {
+		t.Errorf("默认 rank 置信度错误: %v", jetty.Confidence)
+	}
+	if len(top.Conflicts) != 1 || len(jetty.Conflicts) != 1 {
+		t.Errorf("冲突未标记: %v %v", top.Conflicts, jetty.Conflicts)
+	}
+}
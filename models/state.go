@@ -0,0 +1,172 @@
+package models
+
+import (
+	"PrintRaptor/fingerprints"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+/*
+断点续扫的状态文件
+每扫完一个目标追加一行JSON: 目标、规则组(整个规则集的摘要,见 fingerprints.RuleSetID)和聚合结果;
+续扫时跳过同一规则组下已完成的目标并直接输出记录的结果。规则集有任何变化所有目标都会重扫。
+目标扫到一半被中断时追加一条 partial 记录,带上部分结果和已经拿到的响应(同一请求的规则共用一份),
+续扫时这些请求不再重新发送,只补发剩下的
+*/
+
+// StateRecord 状态文件中的一行
+type StateRecord struct {
+	Target  string      `json:"target"`
+	RuleSet string      `json:"ruleSet"`
+	Result  *HostResult `json:"result"`
+	Vhosts  []string    `json:"vhosts,omitempty"` // 目标自身的记录附带扫描时的候选虚拟主机名,续扫时不用重新获取
+	// Partial 目标被中断,Result 为部分结果,Responses 为中断前已经拿到的响应(键同响应缓存)
+	Partial   bool                                  `json:"partial,omitempty"`
+	Responses map[string]*fingerprints.ResponseData `json:"responses,omitempty"`
+}
+
+// State 已完成的目标,可并发调用
+type State struct {
+	mu      sync.Mutex
+	file    *os.File
+	done    map[string]*StateRecord // target + "\n" + ruleSet -> 记录
+	partial map[string]*StateRecord // 被中断的目标,键同 done
+}
+
+// OpenState 打开状态文件,resume 为 false 时重新记录,已有的记录先备份为 path.bak
+func OpenState(path string, resume bool) (*State, error) {
+	state := &State{done: make(map[string]*StateRecord), partial: make(map[string]*StateRecord)}
+	if resume {
+		if err := state.load(path); err != nil {
+			return nil, err
+		}
+	} else if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+		if err := os.Rename(path, path+".bak"); err != nil {
+			return nil, fmt.Errorf("备份状态文件失败: %w", err)
+		}
+		log.Printf("未使用 -resume,重新开始扫描,原有进度已备份到 %s.bak", path)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("打开状态文件失败: %w", err)
+	}
+	state.file = file
+	return state, nil
+}
+
+// load 读取已有的记录,被中断时写了一半的最后一行截掉,之后追加的记录从新的一行开始
+func (s *State) load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取状态文件失败: %w", err)
+	}
+	if end := bytes.LastIndexByte(data, '\n') + 1; end < len(data) {
+		data = data[:end]
+		if err := os.Truncate(path, int64(end)); err != nil {
+			return fmt.Errorf("截断状态文件失败: %w", err)
+		}
+	}
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		record := &StateRecord{}
+		if err := json.Unmarshal(line, record); err != nil || record.Result == nil {
+			continue
+		}
+		key := record.Target + "\n" + record.RuleSet
+		if record.Partial {
+			s.partial[key] = record
+			continue
+		}
+		s.done[key] = record
+		delete(s.partial, key)
+	}
+	return nil
+}
+
+// Done 目标在该规则组下是否已经扫完,返回记录的结果
+func (s *State) Done(target, ruleSet string) (*HostResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.done[target+"\n"+ruleSet]
+	if !ok {
+		return nil, false
+	}
+	return record.Result, true
+}
+
+// Vhosts 目标扫完时记录的候选虚拟主机名,目标还没扫完时返回 false
+func (s *State) Vhosts(target, ruleSet string) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.done[target+"\n"+ruleSet]
+	if !ok {
+		return nil, false
+	}
+	return record.Vhosts, true
+}
+
+// Partial 目标上次被中断前已经拿到的响应,没有中断记录或已经扫完时返回 false
+func (s *State) Partial(target, ruleSet string) (map[string]*fingerprints.ResponseData, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.partial[target+"\n"+ruleSet]
+	if !ok {
+		return nil, false
+	}
+	return record.Responses, true
+}
+
+// Len 已完成的目标数
+func (s *State) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.done)
+}
+
+// Save 记录一个扫完的目标并立即落盘,被中断的结果不记录;vhosts 为目标的候选虚拟主机名
+func (s *State) Save(target, ruleSet string, result *HostResult, vhosts ...string) error {
+	if result == nil || result.Interrupted {
+		return nil
+	}
+	return s.write(&StateRecord{Target: target, RuleSet: ruleSet, Result: result, Vhosts: vhosts})
+}
+
+// SavePartial 记录一个被中断的目标: 部分结果和已经拿到的响应,续扫时接着扫
+func (s *State) SavePartial(target, ruleSet string, result *HostResult, responses map[string]*fingerprints.ResponseData) error {
+	if result == nil || len(responses) == 0 {
+		return nil
+	}
+	return s.write(&StateRecord{Target: target, RuleSet: ruleSet, Result: result, Partial: true, Responses: responses})
+}
+
+func (s *State) write(record *StateRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("写入状态文件失败: %w", err)
+	}
+	key := record.Target + "\n" + record.RuleSet
+	if record.Partial {
+		s.partial[key] = record
+	} else {
+		s.done[key] = record
+		delete(s.partial, key)
+	}
+	return s.file.Sync()
+}
+
+// Close 关闭状态文件
+func (s *State) Close() error {
+	return s.file.Close()
+}
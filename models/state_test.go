@@ -0,0 +1,154 @@
+package models
+
+import (
+	"PrintRaptor/fingerprints"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.state")
+	state, err := OpenState(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := NewHostResult("127.0.0.1:8080")
+	result.AddHit(&fingerprints.CompiledRule{RuleConfig: fingerprints.RuleConfig{Name: "tomcat", Expression: `header="Coyote"`}}, "http://127.0.0.1:8080/")
+	result.Finish()
+	if err := state.Save("http://127.0.0.1:8080", "rs1", result); err != nil {
+		t.Fatal(err)
+	}
+	interrupted := NewHostResult("127.0.0.1:9090")
+	interrupted.Interrupted = true
+	state.Save("http://127.0.0.1:9090", "rs1", interrupted)
+	state.Close()
+
+	// 模拟中断时写了一半的行
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString(`{"target":"http://x","ruleSet":"rs1","res`)
+	f.Close()
+
+	state, err = OpenState(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	restored, ok := state.Done("http://127.0.0.1:8080", "rs1")
+	if !ok || len(restored.Products) != 1 || restored.Products[0].Hits[0].Expression != `header="Coyote"` {
+		t.Fatalf("期望恢复已完成的结果, 实际 %+v", restored)
+	}
+	if _, ok := state.Done("http://127.0.0.1:8080", "rs2"); ok {
+		t.Error("规则组变化后应当重扫")
+	}
+	if _, ok := state.Done("http://127.0.0.1:9090", "rs1"); ok {
+		t.Error("被中断的目标不应记为完成")
+	}
+	// 续扫后追加的记录不能接在写了一半的行后面
+	if err := state.Save("http://127.0.0.1:7070", "rs1", NewHostResult("127.0.0.1:7070")); err != nil {
+		t.Fatal(err)
+	}
+	state.Close()
+	state, err = OpenState(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	if state.Len() != 2 {
+		t.Fatalf("期望恢复 2 个已完成的目标, 实际 %d", state.Len())
+	}
+
+	fresh, err := OpenState(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fresh.Close()
+	if fresh.Len() != 0 {
+		t.Error("不续扫时应清空状态")
+	}
+	if info, err := os.Stat(path + ".bak"); err != nil || info.Size() == 0 {
+		t.Error("不续扫时原有进度应备份")
+	}
+}
+
+// TestStateVhosts 目标自身的记录带上候选虚拟主机名,续扫时不用重新获取
+func TestStateVhosts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.state")
+	state, err := OpenState(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Save("https://10.0.0.1", "rs1", NewHostResult("10.0.0.1"), "a.example.com", "b.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Save("https://10.0.0.2", "rs1", NewHostResult("10.0.0.2")); err != nil {
+		t.Fatal(err)
+	}
+	state.Close()
+
+	state, err = OpenState(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	if vhosts, ok := state.Vhosts("https://10.0.0.1", "rs1"); !ok || len(vhosts) != 2 || vhosts[1] != "b.example.com" {
+		t.Errorf("期望恢复记录的候选主机名, 实际 %v %v", vhosts, ok)
+	}
+	if vhosts, ok := state.Vhosts("https://10.0.0.2", "rs1"); !ok || len(vhosts) != 0 {
+		t.Errorf("没有候选主机名的目标也应记为已获取, 实际 %v %v", vhosts, ok)
+	}
+	if _, ok := state.Vhosts("https://10.0.0.3", "rs1"); ok {
+		t.Error("未扫完的目标需要重新获取候选主机名")
+	}
+}
+
+// TestStatePartial 被中断的目标记录已拿到的响应,续扫时取回;扫完后的记录覆盖中断记录
+func TestStatePartial(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.state")
+	state, err := OpenState(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial := NewHostResult("10.0.0.1")
+	partial.Interrupted = true
+	responses := map[string]*fingerprints.ResponseData{"GET /\n": {Body: "home", Status: 200}}
+	if err := state.SavePartial("http://10.0.0.1", "rs1", partial, responses); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Save("http://10.0.0.1", "rs1", partial); err != nil {
+		t.Fatal(err)
+	}
+	state.SavePartial("http://10.0.0.2", "rs1", partial, responses)
+	state.Close()
+
+	state, err = OpenState(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed, ok := state.Partial("http://10.0.0.1", "rs1")
+	if !ok || resumed["GET /\n"] == nil || resumed["GET /\n"].Body != "home" {
+		t.Fatalf("期望恢复中断前的响应, 实际 %v %v", resumed, ok)
+	}
+	if _, ok := state.Done("http://10.0.0.1", "rs1"); ok || state.Len() != 0 {
+		t.Fatal("被中断的目标不应记为完成")
+	}
+	if _, ok := state.Partial("http://10.0.0.1", "rs2"); ok {
+		t.Error("规则组变化后中断记录不再适用")
+	}
+	if err := state.Save("http://10.0.0.1", "rs1", NewHostResult("10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	state.Close()
+
+	state, err = OpenState(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	if _, ok := state.Partial("http://10.0.0.1", "rs1"); ok {
+		t.Error("扫完后不应再使用中断记录")
+	}
+	if _, ok := state.Partial("http://10.0.0.2", "rs1"); !ok || state.Len() != 1 {
+		t.Errorf("其他目标的中断记录应保留, 已完成 %d 个", state.Len())
+	}
+}
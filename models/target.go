@@ -3,32 +3,80 @@ package models
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"net/url"
 	"os"
+	"strings"
 )
 
 /*
 初始化目标,可从txt文件中读取目标列表,直接传参一个 path string
+非HTTP服务写成 tcp://host:port 或 udp://host:port
+地址后面可以跟空格分隔的候选虚拟主机名,例如 https://10.0.0.1:8443 a.example.com b.example.com
 也可从命令行参数中读取目标,直接传参一个[]string
 */
 
-func LoadFromFile(targetPath string) ([]*url.URL, error) {
-	targets := make([]*url.URL, 0)
+// Target 一个扫描目标,Vhosts 为请求时依次放进 Host 头和 TLS SNI 的候选主机名
+type Target struct {
+	URL    *url.URL
+	Vhosts []string
+}
+
+// ParseTarget 解析目标文件中的一行
+func ParseTarget(line string) (*Target, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("目标为空")
+	}
+	parseUrl, err := url.Parse(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	// tcp://host:port 或 udp://host:port 表示非HTTP服务,必须带端口
+	if (parseUrl.Scheme == "tcp" || parseUrl.Scheme == "udp") && parseUrl.Port() == "" {
+		return nil, fmt.Errorf("目标 %s 缺少端口", line)
+	}
+	return &Target{URL: parseUrl, Vhosts: fields[1:]}, nil
+}
+
+// TargetKey 目标在状态文件和响应缓存中的键,带虚拟主机时写法与目标文件中的一行相同
+func TargetKey(u *url.URL, vhost string) string {
+	if vhost == "" {
+		return u.String()
+	}
+	return u.String() + " " + vhost
+}
+
+func LoadTargets(targetPath string) ([]*Target, error) {
+	targets := make([]*Target, 0)
 	fileBytes, err := os.ReadFile(targetPath)
 	if err != nil {
 		return nil, err
 	}
 	scanner := bufio.NewScanner(bytes.NewReader(fileBytes))
 	for scanner.Scan() {
-		line := scanner.Text()
+		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
 		}
-		parseUrl, err := url.Parse(line)
+		target, err := ParseTarget(line)
 		if err != nil {
 			return nil, err
 		}
-		targets = append(targets, parseUrl)
+		targets = append(targets, target)
 	}
 	return targets, nil
 }
+
+// LoadFromFile 只取目标地址,忽略候选主机名
+func LoadFromFile(targetPath string) ([]*url.URL, error) {
+	targets, err := LoadTargets(targetPath)
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]*url.URL, 0, len(targets))
+	for _, target := range targets {
+		urls = append(urls, target.URL)
+	}
+	return urls, nil
+}
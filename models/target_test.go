@@ -0,0 +1,40 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadTargets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	content := "http://10.0.0.1\n\nhttps://10.0.0.2:8443  a.example.com b.example.com \ntcp://10.0.0.3:6379\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	targets, err := LoadTargets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 3 {
+		t.Fatalf("期望 3 个目标, 实际 %d", len(targets))
+	}
+	if targets[1].URL.Host != "10.0.0.2:8443" || !reflect.DeepEqual(targets[1].Vhosts, []string{"a.example.com", "b.example.com"}) {
+		t.Fatalf("虚拟主机解析错误: %+v", targets[1])
+	}
+	if len(targets[0].Vhosts) != 0 {
+		t.Fatalf("未写虚拟主机时应为空: %+v", targets[0])
+	}
+
+	// 带虚拟主机的键可以按目标文件的写法解析回来
+	key := TargetKey(targets[1].URL, "a.example.com")
+	target, err := ParseTarget(key)
+	if err != nil || target.URL.String() != "https://10.0.0.2:8443" || !reflect.DeepEqual(target.Vhosts, []string{"a.example.com"}) {
+		t.Fatalf("键 %q 解析错误: %+v %v", key, target, err)
+	}
+
+	if _, err := ParseTarget("tcp://10.0.0.3"); err == nil {
+		t.Fatal("tcp 目标缺少端口时应报错")
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"PrintRaptor/engine"
+	"PrintRaptor/fingerprints"
+	"PrintRaptor/models"
+	"context"
+	"fmt"
+)
+
+// runOffline 离线模式: 对缓存中的每个目标用当前规则重新匹配,不产生任何网络请求
+func runOffline(scanner *engine.Scanner, cacheDir string) int {
+	if cacheDir == "" {
+		cacheDir = "cache"
+	}
+	cache, err := models.OpenResponseCache(cacheDir)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	targets, err := cache.Targets()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if len(targets) == 0 {
+		fmt.Printf("缓存目录 %s 中没有响应,先在线扫描一次(配置 CacheDir 或使用 -cache)\n", cacheDir)
+		return 1
+	}
+	scanner.Cache = cache
+	scanner.Offline = true
+	fmt.Printf("🔍 离线匹配 %d 个目标的缓存响应\n", len(targets))
+	for _, key := range targets {
+		// 带虚拟主机的缓存键与目标文件中的一行写法相同
+		target, err := models.ParseTarget(key)
+		if err != nil {
+			fmt.Printf("缓存中的目标 %s 无效: %v\n", key, err)
+			continue
+		}
+		vhost := ""
+		if len(target.Vhosts) > 0 {
+			vhost = target.Vhosts[0]
+		}
+		scanner.ScanVhost(context.Background(), target.URL, vhost).Print()
+	}
+	return 0
+}
+
+// runImport 对已有的流量文件做匹配,不产生任何网络请求
+func runImport(scanner *engine.Scanner, paths []string) int {
+	var traffic []fingerprints.ImportedResponse
+	for _, path := range paths {
+		items, err := fingerprints.LoadTrafficFile(path)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		fmt.Printf("🔍 Loading traffic from %s ,Loaded %d 条响应\n", path, len(items))
+		traffic = append(traffic, items...)
+	}
+	for _, result := range scanner.ScanImported(traffic) {
+		result.Print()
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"PrintRaptor/config"
+	"PrintRaptor/fingerprints"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// listFlag 可重复出现的列表参数,单个值里也可以用逗号分隔
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// scanOptions 扫描模式的命令行参数,设置了的项优先于config.yaml
+type scanOptions struct {
+	filter    fingerprints.RuleFilter
+	resume    bool
+	stateFile string
+	offline   bool
+	cacheDir  string
+	inputs    []string
+	listen    string
+	crawl     int
+	vhosts    []string
+}
+
+func parseScanOptions(args []string) *scanOptions {
+	opts := &scanOptions{}
+	fs := flag.NewFlagSet("PrintRaptor", flag.ExitOnError)
+	fs.Var((*listFlag)(&opts.filter.IncludeTags), "tag", "只加载这些标签的规则,支持通配符和 re: 正则")
+	fs.Var((*listFlag)(&opts.filter.ExcludeTags), "exclude-tag", "排除这些标签的规则")
+	fs.IntVar(&opts.filter.MinRank, "min-rank", 0, "只加载 rank 不低于该值的规则")
+	fs.IntVar(&opts.filter.MaxRank, "max-rank", 0, "只加载 rank 不高于该值的规则")
+	fs.Var((*listFlag)(&opts.filter.IncludeNames), "name", "只加载这些名字的规则")
+	fs.Var((*listFlag)(&opts.filter.ExcludeNames), "exclude-name", "排除这些名字的规则")
+	fs.Var((*listFlag)(&opts.filter.IncludePaths), "path", "只加载这些路径的规则")
+	fs.Var((*listFlag)(&opts.filter.ExcludePaths), "exclude-path", "排除这些路径的规则")
+	fs.BoolVar(&opts.resume, "resume", false, "从状态文件继续上次中断的扫描,跳过已完成的目标")
+	fs.StringVar(&opts.stateFile, "state", "", "状态文件路径,默认取config中的StateFile,未配置时为 printraptor.state")
+	fs.BoolVar(&opts.offline, "offline", false, "离线模式: 不发任何请求,用当前规则对响应缓存重新匹配")
+	fs.StringVar(&opts.cacheDir, "cache", "", "响应缓存目录,默认取config中的CacheDir;设置后在线扫描会缓存所有响应")
+	fs.Var((*listFlag)(&opts.inputs), "input", "不扫描目标,对 HAR、Burp XML 导出或原始响应文件做匹配,可重复")
+	fs.StringVar(&opts.listen, "listen", "", "被动识别代理模式,监听该地址(如 127.0.0.1:8081),转发浏览器流量并实时识别")
+	fs.IntVar(&opts.crawl, "crawl", 0, "同源爬取深度,默认取config中的Crawl.Depth")
+	fs.Var((*listFlag)(&opts.vhosts), "vhost", "对每个目标额外尝试的虚拟主机名(Host 头和 SNI),可重复")
+	_ = fs.Parse(args)
+	return opts
+}
+
+// applyRuleFilter 合并config.yaml中的Filter和命令行参数,设置到规则加载器上
+func applyRuleFilter(opts *scanOptions) error {
+	var filter fingerprints.RuleFilter
+	if err := config.Decode("Filter", &filter); err != nil {
+		return err
+	}
+	cli := opts.filter
+	override := func(dst *[]string, src []string) {
+		if len(src) > 0 {
+			*dst = src
+		}
+	}
+	override(&filter.IncludeTags, cli.IncludeTags)
+	override(&filter.ExcludeTags, cli.ExcludeTags)
+	override(&filter.IncludeNames, cli.IncludeNames)
+	override(&filter.ExcludeNames, cli.ExcludeNames)
+	override(&filter.IncludePaths, cli.IncludePaths)
+	override(&filter.ExcludePaths, cli.ExcludePaths)
+	if cli.MinRank != 0 {
+		filter.MinRank = cli.MinRank
+	}
+	if cli.MaxRank != 0 {
+		filter.MaxRank = cli.MaxRank
+	}
+	if err := fingerprints.SetRuleFilter(&filter); err != nil {
+		return fmt.Errorf("规则过滤条件错误: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"PrintRaptor/engine"
+	"PrintRaptor/http"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runPassive 被动识别代理模式: 转发浏览器的流量,实时输出识别出的产品,Ctrl+C 后输出汇总
+func runPassive(scanner *engine.Scanner, addr string) int {
+	passive := engine.NewPassive(scanner, func(host, product string) {
+		fmt.Printf("[+] %s 识别到 %s\n", host, product)
+	})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := http.ListenPassive(ctx, addr, &http.PassiveProxy{OnResponse: passive.Observe})
+	passive.Close()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Println("\n汇总:")
+	for _, result := range passive.Results() {
+		result.Print()
+	}
+	return 0
+}
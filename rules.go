@@ -0,0 +1,35 @@
+package main
+
+import (
+	"PrintRaptor/config"
+	"PrintRaptor/fingerprints"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// loadRules 按配置加载指纹: 内置指纹库打底,用户指纹文件按规则名覆盖或禁用
+func loadRules() ([]fingerprints.CompiledRule, error) {
+	fingerFilePaths, err := config.GetFingerFilePaths()
+	if err != nil {
+		return nil, fmt.Errorf("初始化指纹文件路径失败: %w", err)
+	}
+	builtin := config.UseBuiltinRules()
+	if !builtin && len(fingerFilePaths) == 0 {
+		return nil, errors.New("未启用内置指纹库,FingerFilePath配置项不能为空")
+	}
+	// 先按规则名叠加再应用过滤条件,被过滤掉的覆盖规则不会让内置的同名规则回来
+	rules, err := fingerprints.LoadLibrary(builtin, fingerFilePaths, config.GetDisabledRules()...)
+	if err != nil {
+		return nil, fmt.Errorf("加载指纹规则失败: %w", err)
+	}
+	sources := fingerFilePaths
+	if builtin {
+		sources = append([]string{"builtin"}, sources...)
+	}
+	fmt.Printf("🔍 Loading rules from %s ,Loaded %d 条\n", strings.Join(sources, ", "), len(rules))
+	if len(rules) == 0 {
+		return nil, errors.New("没有可用的指纹规则")
+	}
+	return rules, nil
+}
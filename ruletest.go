@@ -0,0 +1,46 @@
+package main
+
+import (
+	"PrintRaptor/config"
+	"PrintRaptor/fingerprints"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runRuleTests 用指纹自带的样本做离线回归,有样本不符合预期时返回非零退出码
+// 用法: PrintRaptor test [file|dir|glob ...],不指定时使用config中的FingerFilePath
+func runRuleTests(args []string) int {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	_ = fs.Parse(args)
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		config.Load()
+		fingerFilePaths, err := config.GetFingerFilePaths()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "初始化指纹文件路径失败: %v\n", err)
+			return 2
+		}
+		if len(fingerFilePaths) == 0 {
+			fmt.Fprintln(os.Stderr, "未指定指纹文件,FingerFilePath配置项也为空")
+			return 2
+		}
+		paths = fingerFilePaths
+	}
+
+	rules, err := fingerprints.LoadRulesFromPaths(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	report := fingerprints.RunRuleTests(rules)
+	for _, failure := range report.Failures {
+		fmt.Println(failure.String())
+	}
+	fmt.Printf("%d 条规则带样本, %d 个样本, %d 个失败\n", report.Rules, report.Samples, len(report.Failures))
+	if len(report.Failures) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,27 @@
+package source
+
+import _ "embed"
+
+// Finger 内置的通用指纹库,编译进二进制,没有配置指纹文件也能直接用
+//
+//go:embed finger.yaml
+var Finger []byte
+
+// FingerName 内置指纹库在规则来源中的名字
+const FingerName = "builtin:finger.yaml"
+
+// Service 内置的非HTTP服务banner指纹库
+//
+//go:embed service.yaml
+var Service []byte
+
+// ServiceName 内置服务指纹库在规则来源中的名字
+const ServiceName = "builtin:service.yaml"
+
+// Waf 内置的WAF/CDN识别规则,不参与产品识别,只用于标记和拦截页检测
+//
+//go:embed waf.yaml
+var Waf []byte
+
+// WafName 内置WAF规则在规则来源中的名字
+const WafName = "builtin:waf.yaml"